@@ -0,0 +1,100 @@
+package quickenv
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Allocation budgets for the fast path every service runs at startup.
+// Raise them only deliberately, together with the feature that needs it.
+const (
+	parseLineAllocBudget      = 0
+	loadFromReaderAllocBudget = 8
+)
+
+// benchEnvFile builds an env file with n KEY=VALUE lines, optionally quoted.
+func benchEnvFile(n int, quoted bool) string {
+	var b strings.Builder
+	for i := range n {
+		if i%10 == 0 {
+			b.WriteString("# section comment\n\n")
+		}
+		if quoted {
+			fmt.Fprintf(&b, "BENCH_KEY_%d=\"value number %d\"\n", i, i)
+		} else {
+			fmt.Fprintf(&b, "BENCH_KEY_%d=value_%d\n", i, i)
+		}
+	}
+	return b.String()
+}
+
+func BenchmarkParseLine(b *testing.B) {
+	cases := []struct {
+		name string
+		line string
+	}{
+		{name: "unquoted", line: "DB_PORT=8080"},
+		{name: "quoted", line: `NAME="Alex Edwards"`},
+		{name: "export", line: "export API_KEY=abc123"},
+		{name: "equals_in_quotes", line: `CONN_STR="user=pass@host:5432"`},
+	}
+
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				_, _, _ = parseLine(c.line)
+			}
+		})
+	}
+}
+
+func BenchmarkLoadFromReader(b *testing.B) {
+	cases := []struct {
+		name   string
+		lines  int
+		quoted bool
+	}{
+		{name: "small_unquoted", lines: 10},
+		{name: "small_quoted", lines: 10, quoted: true},
+		{name: "large_unquoted", lines: 1000},
+		{name: "large_quoted", lines: 1000, quoted: true},
+	}
+
+	options := &LoadOptions{Overwrite: true}
+	for _, c := range cases {
+		content := benchEnvFile(c.lines, c.quoted)
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(content)))
+			for b.Loop() {
+				if _, err := loadFromReader(strings.NewReader(content), options); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestParseLineAllocBudget(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _, _ = parseLine(`NAME="Alex Edwards"`)
+	})
+	if allocs > parseLineAllocBudget {
+		t.Errorf("parseLine allocates %.0f times per line, budget is %d", allocs, parseLineAllocBudget)
+	}
+}
+
+func TestLoadFromReaderAllocBudget(t *testing.T) {
+	content := benchEnvFile(1, false)
+	options := &LoadOptions{Overwrite: true}
+	t.Setenv("BENCH_KEY_0", "")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = loadFromReader(strings.NewReader(content), options)
+	})
+	if allocs > loadFromReaderAllocBudget {
+		t.Errorf("loadFromReader allocates %.0f times per one-line file, budget is %d", allocs, loadFromReaderAllocBudget)
+	}
+}
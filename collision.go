@@ -0,0 +1,84 @@
+package quickenv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// CollisionLevel decides how Load reports a collision: a key that a file assigns with a
+// different value than a file it extends, or a platform overlay assigns with a different
+// value than the file it overlays, so the other value is silently shadowed.
+// Collisions are always listed in Result.Collisions.
+type CollisionLevel int
+
+const (
+	// CollisionInfo only records collisions, and logs them if Debug is enabled.
+	CollisionInfo CollisionLevel = iota
+
+	// CollisionWarn also reports each collision to the warning handler, see SetWarningHandler.
+	CollisionWarn
+
+	// CollisionError fails the load without setting any variable.
+	CollisionError
+)
+
+// Collision describes a key assigned with different values by several files.
+// Sources are positions like ".env:3"; values are never recorded.
+type Collision struct {
+	// Key is the variable assigned more than once
+	Key string
+
+	// Winner is where the value that is used was assigned
+	Winner string
+
+	// Losers are where the shadowed values were assigned
+	Losers []string
+}
+
+func (c Collision) String() string {
+	return fmt.Sprintf("%s: %s overrides %v", c.Key, c.Winner, c.Losers)
+}
+
+// collisions collects the entries that resolveExtends and mergePlatformOverlay marked
+// as overridden, in order.
+func collisions(entries []entry) []Collision {
+	var result []Collision
+	index := make(map[string]int) // key and winner -> position in result
+	for _, e := range entries {
+		if e.ignored != "overridden" {
+			continue
+		}
+		id := e.key + "\x00" + e.overriddenBy
+		i, ok := index[id]
+		if !ok {
+			i = len(result)
+			index[id] = i
+			result = append(result, Collision{Key: e.key, Winner: e.overriddenBy})
+		}
+		result[i].Losers = append(result[i].Losers, entryPos(e))
+	}
+	return result
+}
+
+// reportCollisions reports collided according to options.Collisions.
+// With CollisionError, it returns an error listing every collision.
+func reportCollisions(collided []Collision, options *LoadOptions) error {
+	var errs []error
+	for _, c := range collided {
+		switch options.Collisions {
+		case CollisionError:
+			errs = append(errs, errors.New(c.String()))
+		case CollisionWarn:
+			warn("key collision: %s", c)
+		default:
+			if options.Debug {
+				fmt.Fprintf(os.Stderr, "quickenv: [DEBUG] key collision: %s\n", c)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("quickenv: key collision: %w", errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package quickenv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// CSVReport summarizes a LoadCSV call.
+type CSVReport struct {
+	// Loaded is the number of variables set in the environment
+	Loaded int
+
+	// Rejected holds one error per row that was not loaded, e.g. "row 4: invalid key format: db-port"
+	Rejected []error
+}
+
+// LoadCSV loads variables from a CSV file, as exported from spreadsheets or ticketing systems.
+// The first row is the header; keyCol and valueCol name the columns holding keys and values.
+// Files with a ".tsv" extension are read as tab-separated.
+//
+// Rows with an invalid key or missing columns are rejected and listed in the report
+// instead of aborting the import. Overwrite, SkipEmpty and Debug from opts apply as in Load;
+// Pathname and MaxLevels are ignored.
+func LoadCSV(path, keyCol, valueCol string, opts ...*LoadOptions) (CSVReport, error) {
+	options := parseOptions(opts...)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return CSVReport{}, fmt.Errorf("quickenv: failed to open %s:%w", path, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1 // validated per row below
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		reader.Comma = '\t'
+		reader.LazyQuotes = true
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return CSVReport{}, fmt.Errorf("quickenv: %s: read header: %w", path, err)
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff") // UTF-8 byte order mark of Excel exports
+
+	keyIdx := slices.Index(header, keyCol)
+	valueIdx := slices.Index(header, valueCol)
+	if keyIdx == -1 || valueIdx == -1 {
+		return CSVReport{}, fmt.Errorf("quickenv: %s: header must contain columns %q and %q", path, keyCol, valueCol)
+	}
+
+	var report CSVReport
+	var entries []entry
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("quickenv: %s: %w", path, err)
+		}
+		row, _ := reader.FieldPos(0)
+
+		if keyIdx >= len(record) || valueIdx >= len(record) {
+			report.Rejected = append(report.Rejected, fmt.Errorf("row %d: missing columns", row))
+			continue
+		}
+
+		key := strings.TrimSpace(record[keyIdx])
+		if !isValidEnvKey(key) {
+			report.Rejected = append(report.Rejected, fmt.Errorf("row %d: invalid key format: %s", row, key))
+			continue
+		}
+
+		entries = append(entries, entry{line: row, key: key, value: record[valueIdx]})
+	}
+
+	if options.Debug {
+		for _, rejected := range report.Rejected {
+			fmt.Fprintf(os.Stderr, "quickenv: [DEBUG] skip %s: %v\n", path, rejected)
+		}
+	}
+
+	report.Loaded, err = applyEntries(entries, options)
+	return report, err
+}
@@ -0,0 +1,70 @@
+package quickenv
+
+// Dialect describes the syntax of env files that don't use KEY=VALUE with shell quotes,
+// e.g. "KEY: value" exports or backtick-quoted values, so they load without a separate parser.
+// The zero Dialect is the default syntax.
+type Dialect struct {
+	// Assign lists the characters that separate a key from its value; the first one
+	// outside quotes is used, so ":=" accepts both "KEY=value" and "KEY: value" (default: "=")
+	Assign string
+
+	// Quotes lists the ASCII characters that may surround a value (default: a double and a single quote).
+	// Escape sequences are only interpreted in double quotes and only single-quoted values
+	// are taken literally, other quote characters are stripped.
+	Quotes string
+
+	// Escapes lists the characters that may follow a backslash in double quotes:
+	// "n", "r" and "t" stand for control characters, anything else for itself
+	// (default: n, r, t, a double quote and a backslash)
+	Escapes string
+
+	// NoExpansion takes every value literally, like DisableExpansion
+	NoExpansion bool
+
+	// LiteralQuotes keeps nested quoting intact for values like FLAG='--name="x y"':
+	// only the outermost pair of quotes is stripped, escape sequences are not
+	// interpreted, and an inline comment may follow a value made of several quoted
+	// parts, like FLAG="--a='1'" "--b='2'" # comment
+	LiteralQuotes bool
+}
+
+// Dialects of other tools, so a file loads the same in Go as in those tools.
+// Bash and Docker Compose resolve references against the process environment,
+// as quickenv does unless ExpandFileOnly is set.
+var (
+	// DialectCompose follows Docker Compose .env files: shell-like quoting with
+	// escape sequences in double quotes and ${VAR} interpolation.
+	DialectCompose = Dialect{}
+
+	// DialectBash follows files sourced by bash: in double quotes only \", \\, \` and \$
+	// are escapes, so "\n" stays a backslash and an n.
+	DialectBash = Dialect{Escapes: "\"\\`"}
+
+	// DialectNode follows the Node dotenv package: backticks quote too, only \n is an
+	// escape in double quotes and there is no expansion.
+	DialectNode = Dialect{Quotes: "\"'`", Escapes: "n", NoExpansion: true}
+)
+
+// assign returns the assignment characters, see Dialect.Assign.
+func (d Dialect) assign() string {
+	if d.Assign == "" {
+		return "="
+	}
+	return d.Assign
+}
+
+// quotes returns the quote characters, see Dialect.Quotes.
+func (d Dialect) quotes() string {
+	if d.Quotes == "" {
+		return `"'`
+	}
+	return d.Quotes
+}
+
+// escapes returns the escapable characters, see Dialect.Escapes.
+func (d Dialect) escapes() string {
+	if d.Escapes == "" {
+		return "nrt\"\\"
+	}
+	return d.Escapes
+}
@@ -0,0 +1,99 @@
+package quickenv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// EnvForDir returns the merged variables that apply to the directory dir, without
+// touching the process environment. It is meant for build tools that operate across
+// subprojects and need each subproject's environment, direnv-style.
+//
+// Every env file named opts.Pathname found in dir and up to opts.MaxLevels parent
+// directories is part of the chain. Files are merged from the outermost to the
+// innermost, so the file closest to dir wins, as if the chain were one long file:
+// "#extends" directives and platform overlays (PlatformOverlay) are resolved for each
+// file, "unset KEY" removes a key inherited from an outer file, and Extra is injected
+// once for the whole chain. Invalid lines are always an error, as in Parse: nothing is
+// returned and the error joins a *ParseError for every invalid line. Collisions
+// with extended files are reported as in Load, see LoadOptions.Collisions.
+// Returns an empty map if no env file applies.
+func EnvForDir(dir string, opts ...*LoadOptions) (map[string]string, error) {
+	options, err := resolveOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("quickenv: %w", err)
+	}
+
+	// Collect the chain from dir upward (innermost first)
+	var chain []string
+	for level := 0; level <= options.MaxLevels; level++ {
+		path := filepath.Join(dir, options.Pathname)
+		if _, err := os.Stat(path); err == nil {
+			chain = append(chain, path)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break // reached filesystem root
+		}
+		dir = parent
+	}
+
+	var entries []entry
+	var lineErrs []error
+	for i := len(chain) - 1; i >= 0; i-- {
+		file, err := os.Open(chain[i])
+		if err != nil {
+			return nil, fmt.Errorf("quickenv: %w", err)
+		}
+		fileEntries, fileErrs, err := readResolved(file, chain[i], options)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileEntries...)
+		lineErrs = append(lineErrs, fileErrs...)
+	}
+	if len(lineErrs) > 0 {
+		return nil, fmt.Errorf("quickenv: %w", errors.Join(lineErrs...))
+	}
+	if err := reportCollisions(collisions(entries), options); err != nil {
+		return nil, err
+	}
+
+	// References resolve against outer files first, then the process environment unless ExpandFileOnly is set
+	entries, err = finishEntries(entries, options)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]string)
+	mergeEntries(env, entries, options)
+	return env, nil
+}
+
+// mergeEntries applies entries to env in order: assignments replace existing values
+// (unless SkipEmpty or Group drops them) and unset entries delete keys.
+func mergeEntries(env map[string]string, entries []entry, options *LoadOptions) {
+	for _, e := range entries {
+		switch {
+		case e.extends != "", e.ignored != "":
+			continue
+		case e.unset:
+			delete(env, e.key)
+		case !e.inGroup(options.Group):
+			continue
+		case options.SkipEmpty && e.value == "":
+			continue
+		default:
+			env[e.key] = e.value
+		}
+	}
+}
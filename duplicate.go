@@ -0,0 +1,22 @@
+package quickenv
+
+// DuplicatePolicy decides what happens when an env file assigns the same key more than once.
+// Assignments tagged for different groups with "# @group" are not duplicates, and an
+// "unset KEY" line starts over, so a later assignment of KEY is not a duplicate either.
+// The policy applies to each file on its own: a file still overrides the files it extends.
+type DuplicatePolicy int
+
+const (
+	// DuplicateAllow keeps every assignment and leaves the outcome to Overwrite:
+	// the first one wins, unless Overwrite is set and the last one does.
+	DuplicateAllow DuplicatePolicy = iota
+
+	// FirstWins keeps the first assignment of a key and ignores the later ones.
+	FirstWins
+
+	// LastWins keeps the last assignment of a key and ignores the earlier ones.
+	LastWins
+
+	// DuplicateError fails with a *ParseError for every repeated assignment.
+	DuplicateError
+)
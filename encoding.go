@@ -0,0 +1,65 @@
+package quickenv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"unicode/utf16"
+)
+
+// scanLines is bufio.ScanLines without dropping the line ending, so the raw
+// bytes of a file can be reassembled from its tokens when it turns out to be UTF-16.
+// Callers trim the line ending themselves.
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// trimLineEnding removes the "\n" or "\r\n" kept by scanLines.
+func trimLineEnding(line string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+}
+
+// utf16Order reports the byte order announced by a UTF-16 byte order mark
+// at the start of first, or nil if there is none.
+func utf16Order(first string) binary.ByteOrder {
+	switch {
+	case strings.HasPrefix(first, "\xff\xfe"):
+		return binary.LittleEndian
+	case strings.HasPrefix(first, "\xfe\xff"):
+		return binary.BigEndian
+	}
+	return nil
+}
+
+// decodeUTF16 reads the rest of scanner after its first token first and
+// transcodes the whole file from UTF-16 to UTF-8, dropping the byte order mark.
+func decodeUTF16(first string, scanner *bufio.Scanner, order binary.ByteOrder) (string, error) {
+	raw := []byte(first)
+	for scanner.Scan() {
+		raw = append(raw, scanner.Bytes()...)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	raw = raw[2:]
+	if len(raw)%2 != 0 {
+		return "", errors.New("invalid UTF-16: odd number of bytes")
+	}
+
+	units := make([]uint16, len(raw)/2)
+	for i := range units {
+		units[i] = order.Uint16(raw[2*i:])
+	}
+	return string(utf16.Decode(units)), nil
+}
@@ -0,0 +1,34 @@
+package quickenv
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ParseError describes an invalid line in an env file.
+// Load in strict mode and Verify return one per invalid line, joined with errors.Join,
+// so callers can report every broken line with errors.As or by unwrapping the join.
+type ParseError struct {
+	File   string // path of the file, "-" for stdin, empty for in-memory readers
+	Line   int    // 1-based line number; the first line of a continued line
+	Text   string // the offending line, trimmed; may contain secrets, so it is not part of Error
+	Reason string // why the line was rejected
+}
+
+func (e *ParseError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+	}
+	return fmt.Sprintf("%s: line %d: %s", e.File, e.Line, e.Reason)
+}
+
+// wrapReadError prefixes an error returned by readEntries with the source name,
+// unless it consists of ParseErrors, which carry the file themselves, or there is
+// no source name.
+func wrapReadError(source string, err error) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) || source == "" {
+		return fmt.Errorf("quickenv: %w", err)
+	}
+	return fmt.Errorf("quickenv: %s: %w", source, err)
+}
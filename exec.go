@@ -0,0 +1,41 @@
+package quickenv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// Exec loads the env file described by opts and then replaces the current process with
+// argv, which runs with the merged environment (process environment plus loaded values).
+// It lets wrapper binaries be written in a few lines:
+//
+//	func main() {
+//		err := quickenv.Exec(context.Background(), nil, os.Args[1:])
+//		log.Fatal(err)
+//	}
+//
+// On Unix the process image is replaced via execve and Exec only returns on failure.
+// Elsewhere (e.g. Windows) the command is started with the current stdio, Exec waits
+// for it and exits with its exit code; ctx cancellation kills the command.
+func Exec(ctx context.Context, opts *LoadOptions, argv []string) error {
+	if len(argv) == 0 {
+		return errors.New("quickenv: exec: no command given")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("quickenv: exec: %w", err)
+	}
+
+	if _, err := Load(opts); err != nil {
+		return err
+	}
+
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return fmt.Errorf("quickenv: exec: %w", err)
+	}
+
+	return execProcess(ctx, path, argv)
+}
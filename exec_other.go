@@ -0,0 +1,29 @@
+//go:build !unix
+
+package quickenv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// execProcess emulates execve where it is not available: it runs path with the
+// current stdio and environment, waits for it and exits with its exit code.
+func execProcess(ctx context.Context, path string, argv []string) error {
+	cmd := exec.CommandContext(ctx, path, argv[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = os.Environ()
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		os.Exit(0)
+	case errors.As(err, &exitErr):
+		os.Exit(exitErr.ExitCode())
+	}
+	return fmt.Errorf("quickenv: exec %s: %w", path, err)
+}
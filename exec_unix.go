@@ -0,0 +1,18 @@
+//go:build unix
+
+package quickenv
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// execProcess replaces the current process with path via execve.
+func execProcess(_ context.Context, path string, argv []string) error {
+	if err := syscall.Exec(path, argv, os.Environ()); err != nil {
+		return fmt.Errorf("quickenv: exec %s: %w", path, err)
+	}
+	return nil // unreachable: execve does not return on success
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package quickenv
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecReplacesProcess(t *testing.T) {
+	if os.Getenv("QUICKENV_EXEC_HELPER") == "1" {
+		// Runs in the child test binary; on success this never returns.
+		err := Exec(context.Background(), &LoadOptions{Pathname: os.Getenv("QUICKENV_EXEC_FILE")},
+			[]string{"sh", "-c", `printf %s "$EXEC_VALUE"`})
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "exec.env")
+	assert.NoError(t, os.WriteFile(path, []byte("EXEC_VALUE=from-env-file\n"), 0o600))
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestExecReplacesProcess$")
+	cmd.Env = append(os.Environ(), "QUICKENV_EXEC_HELPER=1", "QUICKENV_EXEC_FILE="+path)
+	out, err := cmd.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "from-env-file", string(out))
+}
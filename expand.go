@@ -0,0 +1,476 @@
+package quickenv
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// expandEntries expands ${VAR} and $VAR references in the values of entries, in order.
+// A reference resolves to the value assigned by an earlier entry, falling back to lookup
+// (usually osLookup). Unknown variables expand to an empty string.
+// Single-quoted values are taken literally, as in shell, and nothing is expanded
+// with options.DisableExpansion.
+// With options.AllowCommandSubstitution, $(command) segments are replaced by the output
+// of the command, see runCommand.
+// With options.ExpandForwardRefs, references may also point to later keys, see
+// expandInDependencyOrder.
+// Values are limited to maxExpandedLen bytes each and maxExpandedTotal bytes together.
+func expandEntries(entries []entry, lookup func(string) (string, bool), options *LoadOptions) error {
+	if options.DisableExpansion || options.Dialect.NoExpansion {
+		return nil
+	}
+	if options.ExpandForwardRefs {
+		return expandInDependencyOrder(entries, lookup, options)
+	}
+
+	var budget expansionBudget
+	var defined map[string]string
+	x := &expander{lookup: func(name string) (string, bool) {
+		if value, ok := defined[name]; ok {
+			return value, true
+		}
+		return lookup(name)
+	}}
+
+	for i := range entries {
+		e := &entries[i]
+		switch {
+		case e.key == "", e.ignored != "":
+			continue // ignored line, duplicate or "#extends" directive
+		case e.unset:
+			delete(defined, e.key)
+			continue
+		}
+
+		if e.quote != '\'' {
+			x.command = commandRunner(e, options)
+			x.unescaped = unescaped(*e, options)
+			value, err := x.expand(e.value)
+			if err == nil && value != e.value {
+				err = budget.spend(len(value))
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", entryPos(*e), err)
+			}
+			e.value = value
+		}
+
+		if defined == nil {
+			defined = make(map[string]string)
+		}
+		defined[e.key] = e.value
+	}
+	return nil
+}
+
+// expandInDependencyOrder is expandEntries for options.ExpandForwardRefs. A reference
+// resolves to the value assigned by the nearest earlier entry as usual; if there is none,
+// it resolves to the first later assignment of the key in the file, which is expanded
+// first. A chain of references that leads back to itself is an error.
+func expandInDependencyOrder(entries []entry, lookup func(string) (string, bool), options *LoadOptions) error {
+	positions := make(map[string][]int) // key -> indexes of its assignments and unsets
+	for i, e := range entries {
+		if e.key != "" && e.ignored == "" {
+			positions[e.key] = append(positions[e.key], i)
+		}
+	}
+
+	// definition returns the index of the entry that a reference to name from entry i resolves to, or -1
+	definition := func(i int, name string) int {
+		indexes := positions[name]
+		for k := len(indexes) - 1; k >= 0; k-- {
+			if j := indexes[k]; j < i {
+				if entries[j].unset {
+					return -1
+				}
+				return j
+			}
+		}
+		for _, j := range indexes {
+			if j > i && !entries[j].unset {
+				return j
+			}
+		}
+		return -1
+	}
+
+	const (
+		pending = iota
+		expanding
+		expanded
+	)
+	state := make([]int, len(entries))
+	var budget expansionBudget
+	var chain []string // keys being expanded, for the cycle error
+
+	var expandAt func(i int) error
+	expandAt = func(i int) error {
+		e := &entries[i]
+		switch state[i] {
+		case expanded:
+			return nil
+		case expanding:
+			return fmt.Errorf("%s: expansion cycle: %s -> %s", entryPos(*e), strings.Join(chain, " -> "), e.key)
+		}
+		state[i] = expanding
+		chain = append(chain, e.key)
+
+		if e.quote != '\'' {
+			var refErr error // error expanding a referenced entry, already positioned
+			x := &expander{
+				lookup: func(name string) (string, bool) {
+					j := definition(i, name)
+					if j == -1 {
+						return lookup(name)
+					}
+					if err := expandAt(j); err != nil && refErr == nil {
+						refErr = err
+					}
+					return entries[j].value, true
+				},
+				command:   commandRunner(e, options),
+				unescaped: unescaped(*e, options),
+			}
+
+			value, err := x.expand(e.value)
+			if refErr != nil {
+				return refErr
+			}
+			if err == nil && value != e.value {
+				err = budget.spend(len(value))
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", entryPos(*e), err)
+			}
+			e.value = value
+		}
+
+		chain = chain[:len(chain)-1]
+		state[i] = expanded
+		return nil
+	}
+
+	for i, e := range entries {
+		if e.key == "" || e.ignored != "" || e.unset {
+			continue
+		}
+		if err := expandAt(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// osLookup returns os.LookupEnv, or a lookup that finds nothing if options.ExpandFileOnly
+// is set, so references only resolve against keys defined in the files.
+func osLookup(options *LoadOptions) func(string) (string, bool) {
+	if options.ExpandFileOnly {
+		return func(string) (string, bool) { return "", false }
+	}
+	return os.LookupEnv
+}
+
+// unescaped reports whether the escape sequences in the value of e were interpreted
+// when it was parsed, see unescapeValue.
+func unescaped(e entry, options *LoadOptions) bool {
+	return e.quote == '"' && !options.Dialect.LiteralQuotes
+}
+
+// commandRunner returns the command substitution runner for the value of e,
+// or nil unless options.AllowCommandSubstitution is set.
+func commandRunner(e *entry, options *LoadOptions) func(string) (string, error) {
+	if !options.AllowCommandSubstitution {
+		return nil
+	}
+	return func(command string) (string, error) {
+		warn("%s: running command substitution $(%s) for %s", entryPos(*e), command, e.key)
+		return runCommand(command)
+	}
+}
+
+// maxExpandedLen limits the length of an expanded value, so a file that doubles a
+// value on every line can't exhaust memory.
+const maxExpandedLen = 1 << 20
+
+// maxExpandedTotal limits the combined length of all expanded values of a load, so
+// many references to one large value can't exhaust memory either.
+const maxExpandedTotal = 16 << 20
+
+// expansionBudget counts the bytes of the values changed by expansion in one load.
+type expansionBudget int
+
+// spend adds an expanded value of n bytes, failing once the total exceeds maxExpandedTotal.
+func (b *expansionBudget) spend(n int) error {
+	*b += expansionBudget(n)
+	if *b > maxExpandedTotal {
+		return fmt.Errorf("expanded values exceed %d bytes in total", maxExpandedTotal)
+	}
+	return nil
+}
+
+// expander expands the references in a value.
+type expander struct {
+	lookup func(string) (string, bool)
+
+	// command runs a $(command) substitution; nil keeps them literally
+	command func(string) (string, error)
+
+	// unescaped is set for values whose escape sequences were already interpreted,
+	// where "\$" was turned into "$$" and a backslash is never an escape
+	unescaped bool
+}
+
+// expandValue replaces ${VAR} and $VAR references in value using lookup.
+// Command substitutions are kept literally.
+func expandValue(value string, lookup func(string) (string, bool)) (string, error) {
+	return (&expander{lookup: lookup}).expand(value)
+}
+
+// expand replaces the references in value. "$$" is an escape for a literal "$", and so
+// is "\$" unless x.unescaped is set. A "$" that does not start a reference is kept as is,
+// as is an unterminated "${" or "$(".
+func (x *expander) expand(value string) (string, error) {
+	if !strings.Contains(value, "$") {
+		return value, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); {
+		// \$ and $$ produce a literal "$"
+		if (value[i] == '$' || value[i] == '\\' && !x.unescaped) && i+1 < len(value) && value[i+1] == '$' {
+			b.WriteByte('$')
+			i += 2
+			continue
+		}
+
+		if value[i] != '$' || i+1 == len(value) {
+			b.WriteByte(value[i])
+			i++
+			continue
+		}
+
+		// ${VAR}
+		if value[i+1] == '{' {
+			end := matchBracket(value, i+1)
+			if end == -1 {
+				b.WriteString(value[i:]) // unterminated, keep literally
+				break
+			}
+
+			expanded, err := x.expr(value[i+2 : end])
+			if err != nil {
+				return "", err
+			}
+			if err := writeExpanded(&b, expanded); err != nil {
+				return "", err
+			}
+			i = end + 1
+			continue
+		}
+
+		// $(command)
+		if value[i+1] == '(' && x.command != nil {
+			end := matchBracket(value, i+1)
+			if end == -1 {
+				b.WriteString(value[i:]) // unterminated, keep literally
+				break
+			}
+
+			command, err := x.expand(value[i+2 : end])
+			if err != nil {
+				return "", err
+			}
+			output, err := x.command(command)
+			if err != nil {
+				return "", err
+			}
+			if err := writeExpanded(&b, output); err != nil {
+				return "", err
+			}
+			i = end + 1
+			continue
+		}
+
+		// $VAR
+		n := refNameLen(value[i+1:])
+		if n == 0 {
+			b.WriteByte('$')
+			i++
+			continue
+		}
+		resolved, _ := x.lookup(value[i+1 : i+1+n])
+		if err := writeExpanded(&b, resolved); err != nil {
+			return "", err
+		}
+		i += 1 + n
+	}
+
+	return b.String(), nil
+}
+
+// writeExpanded appends the expansion of a reference to b, failing if the value
+// would grow beyond maxExpandedLen.
+func writeExpanded(b *strings.Builder, expanded string) error {
+	if b.Len()+len(expanded) > maxExpandedLen {
+		return fmt.Errorf("expanded value exceeds %d bytes", maxExpandedLen)
+	}
+	b.WriteString(expanded)
+	return nil
+}
+
+// expr evaluates the inside of a ${...} reference:
+//   - NAME          value of NAME
+//   - NAME:-word    value of NAME, or word if NAME is unset or empty
+//   - NAME-word     value of NAME, or word if NAME is unset
+//   - NAME:?word    value of NAME, or an error with message word if NAME is unset or empty
+//   - NAME?word     value of NAME, or an error with message word if NAME is unset
+//   - NAME:+word    word if NAME is set and not empty, otherwise empty
+//   - NAME+word     word if NAME is set, otherwise empty
+//   - scheme:ref    ref resolved by the resolver registered for scheme, see RegisterResolver
+//
+// The word is expanded itself. Anything else is kept literally.
+func (x *expander) expr(expr string) (string, error) {
+	if value, ok, err := resolveRef(expr, x.expand); ok {
+		return value, err
+	}
+
+	name, op, word := splitExpr(expr)
+	if !isValidEnvKey(name) {
+		return "${" + expr + "}", nil
+	}
+
+	value, ok := x.lookup(name)
+	switch op {
+	case "":
+		return value, nil
+	case ":-":
+		if ok && value != "" {
+			return value, nil
+		}
+		return x.expand(word)
+	case "-":
+		if ok {
+			return value, nil
+		}
+		return x.expand(word)
+	case ":?":
+		if ok && value != "" {
+			return value, nil
+		}
+		return "", x.requiredError(name, word)
+	case "?":
+		if ok {
+			return value, nil
+		}
+		return "", x.requiredError(name, word)
+	case ":+":
+		if ok && value != "" {
+			return x.expand(word)
+		}
+		return "", nil
+	case "+":
+		if ok {
+			return x.expand(word)
+		}
+		return "", nil
+	default:
+		return "${" + expr + "}", nil
+	}
+}
+
+// requiredError builds the error for a failed ${NAME:?message} reference.
+// The message is expanded itself; an empty message gets a shell-like default.
+func (x *expander) requiredError(name, message string) error {
+	message, err := x.expand(message)
+	if err != nil {
+		return err
+	}
+	if message == "" {
+		message = "parameter null or not set"
+	}
+	return fmt.Errorf("%s: %s", name, message)
+}
+
+// splitExpr splits the inside of a ${...} reference into the variable name,
+// the operator (e.g. ":-", or "" for a plain reference) and the word after it.
+func splitExpr(expr string) (string, string, string) {
+	i := strings.IndexAny(expr, ":-?+")
+	if i == -1 {
+		return expr, "", ""
+	}
+
+	name, rest := expr[:i], expr[i:]
+	if strings.HasPrefix(rest, ":") && len(rest) >= 2 {
+		return name, rest[:2], rest[2:]
+	}
+	return name, rest[:1], rest[1:]
+}
+
+// matchBracket returns the index of the '}' or ')' closing the '{' or '(' at open,
+// honoring nesting, or -1 if it is not closed.
+func matchBracket(s string, open int) int {
+	closing := byte('}')
+	if s[open] == '(' {
+		closing = ')'
+	}
+
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case s[open]:
+			depth++
+		case closing:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// refNameLen returns the length of the variable name at the start of s for a bare $VAR
+// reference: an ASCII letter or underscore followed by letters, digits or underscores.
+func refNameLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return i
+		}
+	}
+	return len(s)
+}
+
+// entryPos describes where an entry was read, e.g. ".env:3", "line 3" for plain
+// readers or "Extra" for LoadOptions.Extra.
+func entryPos(e entry) string {
+	switch {
+	case e.file == extraFile:
+		return e.file
+	case e.file == "":
+		return fmt.Sprintf("line %d", e.line)
+	}
+	return fmt.Sprintf("%s:%d", e.file, e.line)
+}
+
+// runCommand runs a $(command) substitution for AllowCommandSubstitution and returns
+// its standard output without trailing newlines, like a shell. The command is split
+// on whitespace and run directly, not through a shell: pipes, globs and quotes have
+// no special meaning.
+func runCommand(command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", nil
+	}
+
+	output, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("command substitution $(%s): %w", command, err)
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
@@ -0,0 +1,156 @@
+package quickenv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// resolveExtends replaces the "#extends PATH" entries of the file at path with the
+// entries of the extended (base) files, so a per-service .env can inherit shared values
+// from e.g. a workspace-level .env.workspace.
+//
+// Relative base paths are resolved against the directory of the extending file,
+// and must stay within options.Root (default: the directory of the loaded file), see checkWithinRoot.
+// Base files may extend other files; a cycle is reported as an error.
+// Base entries come first, and base entries for keys the extending file assigns
+// or unsets itself are dropped, so the extending file always wins. Base assignments
+// shadowed by a different value are kept as "overridden" entries, see collisions.
+//
+// chain holds the absolute paths of the files currently being resolved.
+// Line errors from base files are returned alongside those already collected by the caller.
+func resolveExtends(path string, entries []entry, options *LoadOptions, chain []string) ([]entry, []error, error) {
+	if !slices.ContainsFunc(entries, func(e entry) bool { return e.extends != "" }) {
+		return entries, nil, nil
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("quickenv: %w", err)
+	}
+	chain = append(chain, absPath)
+
+	own := ownEntries(entries)
+
+	var base, result []entry
+	var lineErrs []error
+	for _, e := range entries {
+		result = append(result, e)
+		if e.extends == "" {
+			continue
+		}
+
+		basePath := e.extends
+		if !filepath.IsAbs(basePath) {
+			basePath = filepath.Join(filepath.Dir(absPath), basePath)
+		}
+		if slices.Contains(chain, basePath) {
+			return nil, nil, fmt.Errorf("quickenv: %s:%d: extends cycle via %s", path, e.line, basePath)
+		}
+
+		root := options.Root
+		if root == "" {
+			root = filepath.Dir(chain[0])
+		}
+		realPath, err := checkWithinRoot(basePath, root)
+		if err != nil {
+			return nil, nil, fmt.Errorf("quickenv: %s:%d: %w", path, e.line, err)
+		}
+
+		// Open the checked path, so a symlink swapped in after the check can't escape the root
+		baseEntries, baseErrs, err := readEntriesFromFileAs(realPath, basePath, options)
+		if err != nil {
+			return nil, nil, fmt.Errorf("quickenv: %s:%d: %w", path, e.line, err)
+		}
+		lineErrs = append(lineErrs, baseErrs...)
+
+		baseEntries, baseErrs, err = resolveExtends(basePath, baseEntries, options, chain)
+		if err != nil {
+			return nil, nil, err
+		}
+		lineErrs = append(lineErrs, baseErrs...)
+
+		base = append(base, shadowEntries(baseEntries, own)...)
+
+		if options.Debug {
+			fmt.Fprintf(os.Stderr, "quickenv: [DEBUG] %s extends %s\n", path, basePath)
+		}
+	}
+
+	return append(base, result...), lineErrs, nil
+}
+
+// ownEntries maps each key that entries assign or unset to the first entry doing so.
+func ownEntries(entries []entry) map[string]entry {
+	own := make(map[string]entry)
+	for _, e := range entries {
+		if _, ok := own[e.key]; e.key != "" && e.ignored == "" && !ok {
+			own[e.key] = e
+		}
+	}
+	return own
+}
+
+// shadowEntries drops the entries of base for keys that own assigns or unsets, so
+// the file own belongs to always wins. Base assignments shadowed by a different
+// value are kept as "overridden" entries, see collisions.
+func shadowEntries(base []entry, own map[string]entry) []entry {
+	var result []entry
+	for _, e := range base {
+		winner, ok := own[e.key]
+		switch {
+		case !ok, e.ignored != "":
+			result = append(result, e)
+		case !e.unset && !winner.unset && e.value != winner.value:
+			// Keep the shadowed assignment so it is reported, see collisions
+			e.ignored, e.overriddenBy = "overridden", entryPos(winner)
+			e.reason = "overridden by " + e.overriddenBy
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// readEntriesFromFile opens the file at path and parses it with readEntries.
+func readEntriesFromFile(path string, options *LoadOptions) ([]entry, []error, error) {
+	return readEntriesFromFileAs(path, path, options)
+}
+
+// readEntriesFromFileAs is readEntriesFromFile for a file that is reported as name,
+// e.g. the path an "#extends" directive refers to rather than where it resolved to.
+func readEntriesFromFileAs(path, name string, options *LoadOptions) ([]entry, []error, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s:%w", name, err)
+	}
+	defer file.Close()
+
+	return readEntries(file, name, options)
+}
+
+// ErrOutsideRoot is returned when a referenced file resolves outside the allowed root directory.
+var ErrOutsideRoot = errors.New("path escapes root directory")
+
+// checkWithinRoot verifies that path, after resolving symlinks, lies inside root,
+// so env files from less-trusted sources cannot pull in arbitrary files via "../" or symlinks.
+// It returns the resolved path, which callers must open instead of path.
+func checkWithinRoot(path, root string) (string, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("resolve root %s: %w", root, err)
+	}
+
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s:%w", path, err)
+	}
+
+	rel, err := filepath.Rel(realRoot, realPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: %w %s", path, ErrOutsideRoot, root)
+	}
+	return realPath, nil
+}
@@ -0,0 +1,49 @@
+package quickenv
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// extraFile is the source name of the entries injected from LoadOptions.Extra.
+const extraFile = "Extra"
+
+// injectExtra prepends the variables of options.Extra to entries, sorted by key, so
+// the env files can reference them like any earlier key. Extra values are taken
+// literally. By default an assignment or unset in the files wins and the Extra entry
+// for that key is dropped; with options.ExtraOverrides the file's entries for the key
+// are dropped instead.
+func injectExtra(entries []entry, options *LoadOptions) ([]entry, error) {
+	if len(options.Extra) == 0 {
+		return entries, nil
+	}
+
+	inFile := make(map[string]bool)
+	for _, e := range entries {
+		if e.key != "" && e.ignored == "" {
+			inFile[e.key] = true
+		}
+	}
+
+	var result []entry
+	for _, key := range slices.Sorted(maps.Keys(options.Extra)) {
+		if !isValidEnvKey(key) {
+			return nil, fmt.Errorf("quickenv: invalid Extra key: %s", key)
+		}
+		if inFile[key] && !options.ExtraOverrides {
+			continue
+		}
+		result = append(result, entry{file: extraFile, key: key, value: options.Extra[key], quote: '\''})
+	}
+
+	for _, e := range entries {
+		if options.ExtraOverrides && e.ignored == "" && e.key != "" {
+			if _, ok := options.Extra[e.key]; ok {
+				continue
+			}
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
@@ -0,0 +1,25 @@
+package quickenv
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrFrozen is returned by functions that would modify the environment after Freeze.
+var ErrFrozen = errors.New("quickenv: environment is frozen")
+
+// frozen is set by Freeze and never cleared.
+var frozen atomic.Bool
+
+// Freeze makes the environment immutable as far as quickenv is concerned:
+// afterwards Load, LoadGroup, LoadCSV and every other function that would set or
+// unset variables return ErrFrozen without touching the environment.
+// Call it once startup configuration is complete. It cannot be undone.
+func Freeze() {
+	frozen.Store(true)
+}
+
+// Frozen reports whether Freeze has been called.
+func Frozen() bool {
+	return frozen.Load()
+}
@@ -0,0 +1,106 @@
+package quickenv
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Fuzz targets for the parser. Run them with e.g.
+//
+//	go test -run=^$ -fuzz=FuzzParseLine
+//
+// Crashers found by the fuzzer are written to testdata/fuzz/<FuzzName>/ and
+// must be committed: plain `go test` replays them as regression tests.
+
+// fuzzSeeds are real-world dotenv oddities used as the starting corpus.
+var fuzzSeeds = []string{
+	"DB_PORT=8080",
+	"export API_KEY=abc123",
+	`NAME="Alex Edwards"`,
+	`CITY='New York'`,
+	"\ufeffBOM_KEY=value",
+	"CRLF_KEY=value\r",
+	`NESTED="outer 'inner' \"escaped\""`,
+	`MIXED="unterminated'`,
+	"🔑=emoji",
+	"KEY_🔑=emoji",
+	"ÄKEY=umlaut",
+	"=value",
+	"export",
+	"export =",
+	"unset KEY",
+	"set -a",
+	"KEY==",
+	`"=`,
+	"KEY='=''='",
+}
+
+func FuzzParseLine(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		key, value, err := parseLine(line)
+		if err != nil {
+			return
+		}
+		if !posixKey.MatchString(key) {
+			t.Fatalf("parseLine(%q) accepted invalid key %q", line, key)
+		}
+
+		// Writing the value back double-quoted must parse to the same assignment
+		quoted := key + "=" + quoteValue(value)
+		key2, value2, err := parseLine(quoted)
+		if err != nil || key2 != key || value2 != value {
+			t.Errorf("round trip of %q via %q: got %q=%q, %v; want %q=%q", line, quoted, key2, value2, err, key, value)
+		}
+	})
+}
+
+// posixKey matches the environment variable names the parser may accept.
+var posixKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// quoteValue double-quotes value with the escape sequences the parser interprets.
+func quoteValue(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(value) + `"`
+}
+
+func FuzzLoadFromReader(f *testing.F) {
+	f.Add(strings.Join(fuzzSeeds, "\n"))
+	for _, seed := range fuzzSeeds {
+		f.Add(seed + "\n")
+	}
+
+	f.Fuzz(func(t *testing.T, content string) {
+		defer restoreEnviron(os.Environ())
+
+		// Loading must set exactly what Parse returns
+		options := &LoadOptions{Overwrite: true, Strict: true}
+		vars, parseErr := Parse(strings.NewReader(content), options)
+		count, err := loadFromReader(strings.NewReader(content), options)
+		if parseErr != nil || err != nil {
+			return
+		}
+		if count < len(vars) {
+			t.Errorf("loaded %d variables, Parse returned %d", count, len(vars))
+		}
+		for key, value := range vars {
+			if got := os.Getenv(key); got != value {
+				t.Errorf("%s = %q after loading, Parse returned %q", key, got, value)
+			}
+		}
+	})
+}
+
+// restoreEnviron resets the process environment to a snapshot taken with os.Environ.
+func restoreEnviron(environ []string) {
+	os.Clearenv()
+	for _, kv := range environ {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			os.Setenv(key, value)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package quickenv
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetEnvDuration returns the environment variable named by the key parsed with
+// time.ParseDuration (e.g. "30s", "1h30m"), or defaultValue if it is not set.
+//
+// A bare number such as "30" or "1.5" is ambiguous; it is read as seconds and a warning
+// is reported through the warning handler (see SetWarningHandler). A value that cannot be
+// parsed is reported the same way and defaultValue is returned.
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	if isBareNumber(value) {
+		if d, err := time.ParseDuration(value + "s"); err == nil {
+			warn("%s=%s has no unit, assuming seconds (use e.g. %ss)", key, value, value)
+			return d
+		}
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		warn("%s=%s is not a valid duration, using default %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+// sizeUnits maps size suffixes to their multipliers: decimal (KB, MB, ...)
+// and binary (KiB, MiB, ...). Matching is case-insensitive.
+var sizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	// Longest suffixes first so "KiB" is not matched as "B"
+	{"kib", 1 << 10}, {"mib", 1 << 20}, {"gib", 1 << 30}, {"tib", 1 << 40},
+	{"kb", 1e3}, {"mb", 1e6}, {"gb", 1e9}, {"tb", 1e12},
+	{"b", 1},
+}
+
+// GetEnvBytes returns the environment variable named by the key parsed as a size
+// in bytes, or defaultValue if it is not set. Accepted units are B, KB, MB, GB, TB
+// (powers of 1000) and KiB, MiB, GiB, TiB (powers of 1024), e.g. "512MiB".
+//
+// A bare number such as "10" or "1.5" is read as bytes, but since it is easily meant as
+// megabytes a warning is reported through the warning handler (see SetWarningHandler).
+// A value that cannot be parsed, is negative, is not finite or does not fit in an int64
+// is reported the same way and defaultValue is returned.
+func GetEnvBytes(key string, defaultValue int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	// Bare integers are parsed exactly, the rest through float64
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil && n >= 0 {
+		warn("%s=%s has no unit, assuming bytes (use e.g. %sB or %sMB)", key, value, value, value)
+		return n
+	}
+
+	number, multiplier := value, int64(1)
+	bare := isBareNumber(value)
+	if !bare {
+		multiplier = 0
+		lower := strings.ToLower(value)
+		for _, unit := range sizeUnits {
+			if n, ok := strings.CutSuffix(lower, unit.suffix); ok {
+				number, multiplier = strings.TrimSpace(n), unit.multiplier
+				break
+			}
+		}
+	}
+
+	// NaN fails n >= 0, and float64(math.MaxInt64) rounds up to 2^63, so anything at or
+	// above it overflows
+	n, err := strconv.ParseFloat(number, 64)
+	if size := n * float64(multiplier); err == nil && multiplier != 0 && n >= 0 && size < math.MaxInt64 {
+		if bare {
+			warn("%s=%s has no unit, assuming bytes (use e.g. %sB or %sMB)", key, value, value, value)
+		}
+		return int64(size)
+	}
+
+	warn("%s=%s is not a valid size, using default %d", key, value, defaultValue)
+	return defaultValue
+}
+
+// isBareNumber reports whether value is a decimal number without a unit, e.g. "30",
+// "-5" or "1.5".
+func isBareNumber(value string) bool {
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil && strings.Trim(value, "+-.0123456789") == ""
+}
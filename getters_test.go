@@ -0,0 +1,85 @@
+package quickenv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// captureWarnings routes warnings into the returned slice for the duration of the test.
+func captureWarnings(t *testing.T) *[]string {
+	t.Helper()
+	var warnings []string
+	SetWarningHandler(func(message string) { warnings = append(warnings, message) })
+	t.Cleanup(func() { SetWarningHandler(nil) })
+	return &warnings
+}
+
+func TestGetEnvDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		want     time.Duration
+		wantWarn string
+	}{
+		{name: "unset", value: "", want: time.Minute},
+		{name: "with unit", value: "1h30m", want: 90 * time.Minute},
+		{name: "bare number", value: "30", want: 30 * time.Second, wantWarn: "TIMEOUT=30 has no unit, assuming seconds (use e.g. 30s)"},
+		{name: "bare float", value: "1.5", want: 1500 * time.Millisecond, wantWarn: "TIMEOUT=1.5 has no unit, assuming seconds (use e.g. 1.5s)"},
+		{name: "bare exponent", value: "1e3", want: time.Minute, wantWarn: "TIMEOUT=1e3 is not a valid duration, using default 1m0s"},
+		{name: "invalid", value: "soon", want: time.Minute, wantWarn: "TIMEOUT=soon is not a valid duration, using default 1m0s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := captureWarnings(t)
+			t.Setenv("TIMEOUT", tt.value)
+
+			assert.Equal(t, tt.want, GetEnvDuration("TIMEOUT", time.Minute))
+			if tt.wantWarn == "" {
+				assert.Empty(t, *warnings)
+			} else {
+				assert.Equal(t, []string{tt.wantWarn}, *warnings)
+			}
+		})
+	}
+}
+
+func TestGetEnvBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		want     int64
+		wantWarn bool
+	}{
+		{name: "unset", value: "", want: 64},
+		{name: "bytes", value: "512B", want: 512},
+		{name: "decimal", value: "10MB", want: 10_000_000},
+		{name: "binary", value: "512MiB", want: 512 << 20},
+		{name: "lowercase with space", value: "1.5 gb", want: 1_500_000_000},
+		{name: "bare number", value: "10", want: 10, wantWarn: true},
+		{name: "bare float", value: "1.5", want: 1, wantWarn: true},
+		{name: "bare negative", value: "-5", want: 64, wantWarn: true},
+		{name: "bare negative float", value: "-1.5", want: 64, wantWarn: true},
+		{name: "bare overflow", value: "99999999999999999999", want: 64, wantWarn: true},
+		{name: "invalid", value: "lots", want: 64, wantWarn: true},
+		{name: "negative", value: "-1KB", want: 64, wantWarn: true},
+		{name: "inf", value: "inf", want: 64, wantWarn: true},
+		{name: "inf with unit", value: "infB", want: 64, wantWarn: true},
+		{name: "NaN", value: "NaN", want: 64, wantWarn: true},
+		{name: "NaN with unit", value: "NaNKB", want: 64, wantWarn: true},
+		{name: "overflow", value: "1e30GB", want: 64, wantWarn: true},
+		{name: "overflow after multiplier", value: "10000000TB", want: 64, wantWarn: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := captureWarnings(t)
+			t.Setenv("SIZE", tt.value)
+
+			assert.Equal(t, tt.want, GetEnvBytes("SIZE", 64))
+			assert.Equal(t, tt.wantWarn, len(*warnings) == 1)
+		})
+	}
+}
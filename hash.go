@@ -0,0 +1,50 @@
+package quickenv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ConfigHash returns a stable hash of the configuration resolved by the most recent Load:
+// every key assigned in the loaded file together with its current value in the environment.
+// Two processes with identical configuration produce the same hash, so it is safe to
+// expose as a log field or metric label. It returns "" if nothing was loaded yet.
+//
+// The hash is computed as follows, so other tools can reproduce it:
+//   - keys that are not set in the environment are ignored,
+//   - the remaining keys are sorted in byte order,
+//   - each one is written as KEY=hex(sha256(value)) followed by "\n",
+//   - the result is hex(sha256(all lines)).
+//
+// Values are hashed individually, so the input of the final hash never contains a raw secret.
+func ConfigHash() string {
+	lastLoad.Lock()
+	keys := slices.Clone(lastLoad.keys)
+	loaded := lastLoad.path != ""
+	lastLoad.Unlock()
+
+	if !loaded {
+		return ""
+	}
+
+	slices.Sort(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		valueSum := sha256.Sum256([]byte(value))
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(hex.EncodeToString(valueSum[:]))
+		b.WriteByte('\n')
+	}
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,44 @@
+package quickenv
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"runtime"
+)
+
+// platformOverlay returns the path of the overlay for the current platform next to
+// the env file at path, e.g. ".env.windows" or ".env.darwin" for ".env".
+func platformOverlay(path string) string {
+	return path + "." + runtime.GOOS
+}
+
+// mergePlatformOverlay merges the platform overlay of the env file at path, if it
+// exists, after entries: the overlay's "#extends" directives are resolved, and entries
+// for keys the overlay assigns or unsets are dropped, so the overlay always wins.
+// Assignments shadowed by a different value are kept as "overridden" entries, as for
+// "#extends", see collisions. Line errors from the overlay are returned.
+func mergePlatformOverlay(path string, entries []entry, options *LoadOptions) ([]entry, []error, error) {
+	overlay := platformOverlay(path)
+	if _, err := os.Stat(overlay); errors.Is(err, fs.ErrNotExist) {
+		return entries, nil, nil
+	}
+
+	overlayEntries, lineErrs, err := readEntriesFromFile(overlay, options)
+	if err != nil {
+		return nil, nil, wrapReadError(overlay, err)
+	}
+	overlayEntries, baseErrs, err := resolveExtends(overlay, overlayEntries, options, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	lineErrs = append(lineErrs, baseErrs...)
+
+	result := shadowEntries(entries, ownEntries(overlayEntries))
+
+	if options.Debug {
+		fmt.Fprintf(os.Stderr, "quickenv: [DEBUG] %s overlays %s\n", overlay, path)
+	}
+	return append(result, overlayEntries...), lineErrs, nil
+}
@@ -2,12 +2,17 @@ package quickenv
 
 import (
 	"bufio"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
-	"unicode"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 // Version of the quickenv package.
@@ -15,7 +20,7 @@ const Version = "1.0.0"
 
 // LoadOptions configures how environment variables are loaded.
 type LoadOptions struct {
-	// Pathname is the path of the env file to load (default: ".env")
+	// Pathname is the path of the env file to load, or "-" for standard input (default: ".env")
 	Pathname string
 
 	// Overwrite existing environment variables (default: false)
@@ -26,6 +31,140 @@ type LoadOptions struct {
 
 	// MaxLevels limits how many directories up to search for the env file (default: 3)
 	MaxLevels int
+
+	// Strict makes Load fail on invalid lines instead of skipping them. Nothing is set
+	// and the error joins a *ParseError for every invalid line, e.g. to fail a CI build (default: false)
+	Strict bool
+
+	// NormalizeKeys accepts keys from other tooling by upper-casing them and replacing
+	// '-' and '.' with '_', so "db-port=5432" is loaded as DB_PORT (default: false)
+	NormalizeKeys bool
+
+	// KeyDelimiter accepts hierarchical keys from INI or TOML-minded teams: keys are split
+	// at the delimiter, upper-cased and joined with '_', so with "." the key
+	// "app.server.port" is loaded as APP_SERVER_PORT (default: "", keys are used as written)
+	KeyDelimiter string
+
+	// DuplicatePolicy decides what happens when a file assigns the same key more than once,
+	// see DuplicatePolicy (default: DuplicateAllow)
+	DuplicatePolicy DuplicatePolicy
+
+	// Dialect configures the assignment operators and quote characters for files
+	// that don't use KEY=VALUE with shell quotes, see Dialect (default: KEY=VALUE)
+	Dialect Dialect
+
+	// RawValues keeps values byte for byte after the first '=': no trimming, unquoting,
+	// escape sequences, inline comments, line continuation or expansion (default: false)
+	RawValues bool
+
+	// DisableExpansion takes every value literally: ${VAR}, $VAR, "\$" and "$$" are
+	// kept as written, e.g. for files full of password hashes or templates (default: false)
+	DisableExpansion bool
+
+	// Extra holds variables computed at runtime, e.g. the hostname or build version, that
+	// are loaded together with the env files and can be referenced from them (default: nil)
+	Extra map[string]string
+
+	// ExtraOverrides gives Extra priority over the env files. By default a key assigned
+	// in the files wins and Extra only provides defaults (default: false)
+	ExtraOverrides bool
+
+	// ExpandFileOnly resolves ${VAR} references only against keys defined in the env
+	// files, so the result is reproducible. By default references fall back to the
+	// process environment, e.g. "${HOME}" for local development (default: false)
+	ExpandFileOnly bool
+
+	// ExpandForwardRefs lets ${VAR} refer to a key assigned later in the file, e.g.
+	// "A=${B}" before "B=${C}": values are expanded in dependency order and a cycle
+	// like "A=${B}", "B=${A}" is an error. By default references only see earlier keys,
+	// as in shell (default: false)
+	ExpandForwardRefs bool
+
+	// ExportFromOS makes bare "export KEY" lines, which re-export a shell variable, copy
+	// the current value of KEY from the process environment into the loaded keys, so
+	// later lines can reference it. By default such lines are ignored (default: false)
+	ExportFromOS bool
+
+	// AllowCommandSubstitution replaces $(command args) in values with the output of
+	// the command, e.g. "GIT_SHA=$(git rev-parse HEAD)". Every command is reported to the
+	// warning handler. Only enable it for env files you trust (default: false)
+	AllowCommandSubstitution bool
+
+	// LineParser, if set, replaces the built-in parsing of assignment lines for custom
+	// syntaxes, e.g. prefix conventions or encrypted markers. It receives each trimmed
+	// line that is not blank, a comment or a directive and returns the key and value;
+	// an empty key skips the line and an error makes it an invalid line. The value is
+	// expanded like an unquoted one (default: nil)
+	LineParser func(line string) (key, value string, err error)
+
+	// CommentPrefixes are additional prefixes that start a comment line, e.g. ";" or "//"
+	// for INI or properties exports. "#" always starts a comment line, so "#extends" and
+	// "# @annotations" keep working, and inline comments always start with "#" (default: none)
+	CommentPrefixes []string
+
+	// Collisions decides how keys overridden with a different value by an extending file
+	// are reported, see CollisionLevel (default: CollisionInfo)
+	Collisions CollisionLevel
+
+	// SkipEmpty skips variables with empty values (e.g. "FOO="), so they don't
+	// shadow the fallback passed to GetEnv (default: false)
+	SkipEmpty bool
+
+	// Group selects the process group to load. Variables annotated with "# @group NAME"
+	// are only loaded if NAME matches; variables without the annotation are shared by
+	// all groups. An empty Group skips all annotated variables (default: "")
+	Group string
+
+	// PlatformOverlay merges an overlay for the current operating system after the env
+	// file when it exists, e.g. ".env.windows" or ".env.darwin" next to ".env", so
+	// platform-specific paths don't need separate scripts. The overlay wins (default: false)
+	PlatformOverlay bool
+
+	// Root is the directory that files referenced by "#extends" must stay within, after
+	// resolving symlinks. Monorepos extending a shared file in a parent directory set it
+	// to the repository root (default: the directory of the loaded env file)
+	Root string
+
+	// Timeout limits how long locating and reading the env file may take, so a hung
+	// network file system fails with a clear error instead of blocking startup (default: 0, no limit)
+	Timeout time.Duration
+
+	// Trace, if set, receives a record of every line and the action taken for it,
+	// see Trace. Its previous contents are replaced on each load (default: nil)
+	Trace *Trace
+
+	// PreLoad is called before the env file is located and may modify the options,
+	// e.g. to pick a file by hostname. Returning an error aborts the load (default: nil)
+	PreLoad func(*LoadOptions) error
+
+	// PostLoad is called after the variables have been set, e.g. to verify or register
+	// the configuration. Its error is returned by Load (default: nil)
+	PostLoad func(Result) error
+}
+
+// Result describes a completed Load and is passed to the PostLoad hook.
+type Result struct {
+	// Path is the env file that was loaded
+	Path string
+
+	// Loaded is the number of variables set in the environment
+	Loaded int
+
+	// Keys lists the keys assigned in the file, in file order
+	Keys []string
+
+	// Collisions lists the keys whose value from an extended file was overridden
+	// with a different value, see CollisionLevel
+	Collisions []Collision
+
+	// Sources maps each key to where its value came from: a position like ".env:3",
+	// "environment" if the variable was already set and kept, or why it was skipped
+	Sources map[string]string
+
+	// Annotations maps each key to the "# @name value" comments above its assignment,
+	// e.g. {"PORT": {"type": "int", "required": ""}} for "# @type int" and "# @required",
+	// so validation or code generation can use them without a separate schema
+	Annotations map[string]map[string]string
 }
 
 // DefaultLoadOptions returns the default loading options
@@ -42,35 +181,336 @@ func DefaultLoadOptions() *LoadOptions {
 // If no pathname is provided, it defaults to ".env" in the current directory.
 // Returns the number of variables loaded and any error encountered.
 func Load(opts ...*LoadOptions) (int, error) {
-	options := parseOptions(opts...)
+	options, err := resolveOptions(opts...)
+	if err != nil {
+		return 0, err
+	}
 
-	filePath, err := findEnvFile(options.Pathname, options.MaxLevels)
+	if options.PostLoad != nil && options.Trace == nil {
+		options.Trace = &Trace{} // for Result.Sources
+	}
+	options.Trace.reset()
+
+	parsed, err := readEnvFile(options)
 	if err != nil {
-		return 0, fmt.Errorf("quickenv: %w", err)
+		return 0, err
 	}
+	filePath, entries := parsed.path, parsed.entries
 
-	file, err := os.Open(filePath)
+	if options.Strict && len(parsed.lineErrs) > 0 {
+		return 0, fmt.Errorf("quickenv: %w", errors.Join(parsed.lineErrs...))
+	}
+
+	collided := collisions(entries)
+	if err := reportCollisions(collided, options); err != nil {
+		return 0, err
+	}
+
+	count, err := applyEntries(entries, options)
 	if err != nil {
-		return 0, fmt.Errorf("quickenv: failed to open %s:%w", filePath, err)
+		return count, err
 	}
-	defer file.Close()
 
-	return loadFromReader(file, options)
+	recordLoad(filePath, parsed.modTime, entries)
+
+	if options.PostLoad != nil {
+		result := Result{Path: filePath, Loaded: count, Keys: assignedKeys(entries), Collisions: collided, Sources: traceSources(options.Trace), Annotations: annotations(entries)}
+		if err := options.PostLoad(result); err != nil {
+			return count, fmt.Errorf("quickenv: post-load hook: %w", err)
+		}
+	}
+
+	return count, nil
+}
+
+// LoadGroup is like Load but only loads the variables of the given process group
+// (e.g. "web", "worker", "cron") plus the shared ones, see LoadOptions.Group.
+func LoadGroup(group string, opts ...*LoadOptions) (int, error) {
+	options := parseOptions(opts...)
+	options.Group = group
+	return Load(options)
 }
 
 // MustLoad is like Load but panics if an error occurs.
 // Useful for initialization in main() functions.
+// The error is passed to the fatal handler first, see SetFatalHandler.
 func MustLoad(opts ...*LoadOptions) int {
 	count, err := Load(opts...)
 	if err != nil {
-		panic(fmt.Sprintf("quickenv: %s", err))
+		fatal(err)
 	}
 
 	return count
 }
 
+var (
+	fatalMu      sync.RWMutex
+	fatalHandler func(error)
+)
+
+// SetFatalHandler sets the function that MustLoad and GetEnvOrPanic call on failure,
+// e.g. to route the error through a structured logger or crash reporter before exiting.
+// The handler is expected to terminate the program; if it returns, the Must* function
+// still panics. Passing nil restores the default behavior (panic only).
+func SetFatalHandler(handler func(error)) {
+	fatalMu.Lock()
+	defer fatalMu.Unlock()
+	fatalHandler = handler
+}
+
+// fatal reports err to the fatal handler, if any, and panics with its message.
+func fatal(err error) {
+	fatalMu.RLock()
+	handler := fatalHandler
+	fatalMu.RUnlock()
+
+	if handler != nil {
+		handler(err)
+	}
+	panic(err.Error())
+}
+
+var (
+	warningMu      sync.RWMutex
+	warningHandler func(string)
+)
+
+// SetWarningHandler sets the function that receives warnings about values that parse
+// but look suspicious, e.g. a duration without a unit. By default warnings are
+// written to stderr. Passing nil restores the default.
+func SetWarningHandler(handler func(message string)) {
+	warningMu.Lock()
+	defer warningMu.Unlock()
+	warningHandler = handler
+}
+
+// warn reports a formatted warning to the warning handler, or stderr by default.
+func warn(format string, args ...any) {
+	warningMu.RLock()
+	handler := warningHandler
+	warningMu.RUnlock()
+
+	message := fmt.Sprintf(format, args...)
+	if handler == nil {
+		fmt.Fprintf(os.Stderr, "quickenv: [WARN] %s\n", message)
+		return
+	}
+	handler(message)
+}
+
+// Verify checks that the env file can be found and that every line parses,
+// without setting any environment variables. It is meant as a preflight check,
+// e.g. in a container entrypoint, so a broken file fails the deploy early.
+// All invalid lines are reported together. Collisions are reported as in Load,
+// so with CollisionError a file that Load would reject fails here too.
+func Verify(opts ...*LoadOptions) error {
+	options, err := resolveOptions(opts...)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := readEnvFile(options)
+	if err != nil {
+		return err
+	}
+
+	if len(parsed.lineErrs) > 0 {
+		return fmt.Errorf("quickenv: %w", errors.Join(parsed.lineErrs...))
+	}
+	return reportCollisions(collisions(parsed.entries), options)
+}
+
+// Parse reads env file content from r, e.g. an HTTP body or a test fixture, and
+// returns the resulting variables without touching the environment. Values are
+// expanded like in Load, so ${VAR} falls back to the process environment unless
+// ExpandFileOnly is set, and "unset KEY" removes a key from the result.
+//
+// Pathname, Overwrite and the hooks in opts are not used, and "#extends" directives
+// are not followed since a reader has no location. Invalid lines are always an error:
+// nothing is returned and the error joins a *ParseError for every invalid line.
+func Parse(r io.Reader, opts ...*LoadOptions) (map[string]string, error) {
+	options := parseOptions(opts...)
+
+	entries, lineErrs, err := loadEntries(r, "", options)
+	if len(lineErrs) > 0 {
+		return nil, fmt.Errorf("quickenv: %w", errors.Join(lineErrs...))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make(map[string]string)
+	for _, e := range entries {
+		switch {
+		case e.ignored != "" || e.extends != "":
+		case e.unset:
+			delete(vars, e.key)
+		case !e.inGroup(options.Group) || options.SkipEmpty && e.value == "":
+		default:
+			vars[e.key] = e.value
+		}
+	}
+	return vars, nil
+}
+
 // Helper functions
 
+// envFile is an env file that has been located and parsed, with "#extends" resolved.
+type envFile struct {
+	path     string
+	modTime  time.Time
+	entries  []entry
+	lineErrs []error
+}
+
+// readEnvFile locates and parses the env file described by options.
+// If options.Timeout is set and the file system does not answer in time
+// (e.g. a hung NFS/SMB mount), it returns an error wrapping os.ErrDeadlineExceeded.
+// The blocked file operation itself cannot be interrupted and is left running.
+func readEnvFile(options *LoadOptions) (*envFile, error) {
+	if options.Timeout <= 0 {
+		return readEnvFileNow(options)
+	}
+
+	type result struct {
+		file *envFile
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		file, err := readEnvFileNow(options)
+		done <- result{file, err}
+	}()
+
+	timer := time.NewTimer(options.Timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		return r.file, r.err
+	case <-timer.C:
+		return nil, fmt.Errorf("quickenv: reading %s timed out after %s: %w", options.Pathname, options.Timeout, os.ErrDeadlineExceeded)
+	}
+}
+
+// readEnvFileNow is readEnvFile without the timeout.
+func readEnvFileNow(options *LoadOptions) (*envFile, error) {
+	if options.Pathname == "-" {
+		return readStdin(options)
+	}
+
+	file, err := openEnvFile(options)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	filePath := file.Name()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("quickenv: failed to stat %s: %w", filePath, err)
+	}
+
+	entries, lineErrs, err := loadEntries(file, filePath, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &envFile{
+		path:     filePath,
+		modTime:  info.ModTime(),
+		entries:  entries,
+		lineErrs: lineErrs,
+	}, nil
+}
+
+// readStdin parses env content from standard input, e.g. "sops -d secrets.env | app".
+// "#extends" paths are resolved against the current directory.
+func readStdin(options *LoadOptions) (*envFile, error) {
+	entries, lineErrs, err := loadEntries(os.Stdin, options.Pathname, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &envFile{path: options.Pathname, entries: entries, lineErrs: lineErrs}, nil
+}
+
+// loadEntries runs the steps every way of loading shares: readResolved, then
+// finishEntries. The line errors found so far are returned even if a later step fails,
+// so callers that reject invalid lines can report them first.
+func loadEntries(reader io.Reader, source string, options *LoadOptions) ([]entry, []error, error) {
+	entries, lineErrs, err := readResolved(reader, source, options)
+	if err != nil {
+		return nil, lineErrs, err
+	}
+
+	entries, err = finishEntries(entries, options)
+	return entries, lineErrs, err
+}
+
+// readResolved parses env content from reader with readEntries and resolves what it
+// pulls in: "#extends" directives are followed and, with PlatformOverlay, the platform
+// overlay of source is merged. source is the path of the file, "-" for standard input,
+// which has no overlay, or "" for a plain reader, which has no location, so its
+// "#extends" directives are not followed. Line errors of every file read are returned,
+// along with the error if a file can't be read or resolved.
+func readResolved(reader io.Reader, source string, options *LoadOptions) ([]entry, []error, error) {
+	entries, lineErrs, err := readEntries(reader, source, options)
+	if err != nil {
+		name := source
+		if source == "-" {
+			name = "stdin"
+		}
+		return nil, lineErrs, wrapReadError(name, err)
+	}
+	if source == "" {
+		return entries, lineErrs, nil
+	}
+
+	entries, baseErrs, err := resolveExtends(source, entries, options, nil)
+	if err != nil {
+		return nil, lineErrs, err
+	}
+	lineErrs = append(lineErrs, baseErrs...)
+
+	if options.PlatformOverlay && source != "-" {
+		entries, baseErrs, err = mergePlatformOverlay(source, entries, options)
+		if err != nil {
+			return nil, lineErrs, err
+		}
+		lineErrs = append(lineErrs, baseErrs...)
+	}
+	return entries, lineErrs, nil
+}
+
+// finishEntries injects options.Extra into entries and expands their values,
+// the last steps before entries are applied, see injectExtra and expandEntries.
+func finishEntries(entries []entry, options *LoadOptions) ([]entry, error) {
+	entries, err := injectExtra(entries, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := expandEntries(entries, osLookup(options), options); err != nil {
+		return nil, fmt.Errorf("quickenv: %w", err)
+	}
+	return entries, nil
+}
+
+// openEnvFile locates the env file described by options and opens it for reading.
+func openEnvFile(options *LoadOptions) (*os.File, error) {
+	filePath, err := findEnvFile(options.Pathname, options.MaxLevels)
+	if err != nil {
+		return nil, fmt.Errorf("quickenv: %w", err)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("quickenv: failed to open %s:%w", filePath, err)
+	}
+	return file, nil
+}
+
 // parseOptions processes the provided LoadOptions and applies default values
 // for missing or invalid fields. Always returns a valid *LoadOptions.
 //
@@ -99,6 +539,20 @@ func parseOptions(opts ...*LoadOptions) *LoadOptions {
 	return DefaultLoadOptions()
 }
 
+// resolveOptions is parseOptions followed by the PreLoad hook, if any.
+// Defaults are applied again after the hook so it cannot leave the options invalid.
+func resolveOptions(opts ...*LoadOptions) (*LoadOptions, error) {
+	options := parseOptions(opts...)
+	if options.PreLoad == nil {
+		return options, nil
+	}
+
+	if err := options.PreLoad(options); err != nil {
+		return nil, fmt.Errorf("quickenv: pre-load hook: %w", err)
+	}
+	return parseOptions(options), nil
+}
+
 // findEnvFile looks for a file named pathname starting in the current directory.
 // If not found and maxLevels > 0, it searches up to maxLevels levels in parent directories.
 // Returns the path on success, or an error if not found.
@@ -132,42 +586,355 @@ func findEnvFile(pathname string, maxLevels int) (string, error) {
 	return "", fmt.Errorf("env file not found: %s", pathname)
 }
 
-// loadFromReader reads environment variables from an io.Reader (e.g. file, buffer).
+// entry is a single assignment or unset directive read from an env file.
+type entry struct {
+	file    string // source file, "-" for stdin, empty for plain readers
+	line    int    // 1-based line number in the source
+	key     string
+	value   string
+	quote   byte   // quote character that surrounded the value, 0 if unquoted
+	unset   bool   // "unset KEY" directive rather than an assignment
+	extends string // "#extends PATH" directive rather than an assignment
+
+	// annotations holds the "# @name value" comments directly preceding an assignment
+	annotations map[string]string
+
+	// ignored is set for lines kept only for the Trace: "blank", "comment", "directive",
+	// "invalid" or "duplicate"; and for base entries "overridden" by an extending file
+	ignored string
+	reason  string
+
+	// overriddenBy is the position of the entry that overrides an "overridden" one
+	overriddenBy string
+}
+
+// readEntries parses env content from an io.Reader without touching the environment.
 // Parses each non-empty, non-comment line as KEY=VALUE, optionally with quotes and 'export' prefix.
-// Skips invalid lines and logs them if Debug is enabled.
+// Values are returned unexpanded, see expandEntries.
+// "unset KEY [KEY...]" lines become unset entries, "set -a" and "set +a" are ignored.
+// Bare "export KEY [KEY...]" lines are ignored or, with ExportFromOS, copy the OS values.
+// "#extends PATH" lines become extends entries, see resolveExtends.
+// "# @name value" comments annotate the line right below them, see parseAnnotation;
+// plain comments may come in between, but a blank line ends the annotation block.
+// A line ending with a backslash continues on the next line; the pieces are joined
+// with the continuation line's leading whitespace removed.
+// KEY="""...""" raw blocks are taken verbatim and may span lines, see readRawBlock.
+// Windows line endings and a leading UTF-8 byte order mark are accepted, and a file
+// starting with a UTF-16 byte order mark is transcoded to UTF-8 first, so a file
+// edited or exported on Windows loads the same everywhere.
+//
+// Invalid lines are skipped, logged if Debug is enabled, and returned as *ParseError
+// line errors so the caller decides whether they matter. Repeated assignments are
+// handled according to options.DuplicatePolicy. The final error is only set on read
+// failures and, with DuplicateError, on repeated assignments.
+func readEntries(reader io.Reader, file string, options *LoadOptions) ([]entry, []error, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(scanLines)
+	var entries []entry
+	var lineErrs []error
+	lineNum := 0                  // first physical line of the current logical line
+	physical := 0                 // physical lines read so far
+	var pending map[string]string // annotations waiting for the next line
+	var assigned map[string]int   // key and group -> index of the winning assignment
+	var dupErrs []error
+
+	// ignore keeps a non-assignment line as an entry only when a trace is requested
+	ignore := func(kind, reason string) {
+		if options.Trace != nil {
+			entries = append(entries, entry{file: file, line: lineNum, ignored: kind, reason: reason})
+		}
+	}
+
+	invalid := func(line string, err error) {
+		if options.Debug {
+			fmt.Fprintf(os.Stderr, "quickenv: [DEBUG] skip invalid line %q: %v\n", line, err)
+		}
+		lineErrs = append(lineErrs, &ParseError{File: file, Line: lineNum, Text: line, Reason: err.Error()})
+		ignore("invalid", err.Error())
+	}
+
+	for scanner.Scan() {
+		physical++
+		lineNum = physical
+		text := scanner.Text()
+		if physical == 1 {
+			if order := utf16Order(text); order != nil {
+				decoded, err := decodeUTF16(text, scanner, order)
+				if err != nil {
+					return nil, nil, err
+				}
+				scanner = bufio.NewScanner(strings.NewReader(decoded))
+				scanner.Split(scanLines)
+				physical = 0
+				continue
+			}
+			text = strings.TrimPrefix(text, "\ufeff") // UTF-8 byte order mark
+		}
+		text = trimLineEnding(text)
+		line := strings.TrimSpace(text)
+
+		// Join lines ending with a backslash with the next line
+		for !options.RawValues && !isComment(line, options.CommentPrefixes) && hasContinuation(line) && rawBlockStart(line, options.Dialect) < 0 {
+			line = line[:len(line)-1]
+			if !scanner.Scan() {
+				break
+			}
+			physical++
+			line += strings.TrimSpace(scanner.Text())
+		}
+
+		// Handle "#extends PATH" directives, resolved by resolveExtends
+		if rest, ok := strings.CutPrefix(line, "#extends "); ok {
+			pending = nil
+			entries = append(entries, entry{file: file, line: lineNum, extends: strings.TrimSpace(rest)})
+			continue
+		}
+
+		// Skip empty lines and comments
+		// A blank line ends a block of annotations
+		if line == "" {
+			pending = nil
+			ignore("blank", "")
+			continue
+		}
+		if isComment(line, options.CommentPrefixes) {
+			if name, value, ok := parseAnnotation(line); ok {
+				if pending == nil {
+					pending = make(map[string]string)
+				}
+				pending[name] = value
+			}
+			ignore("comment", "")
+			continue
+		}
+
+		// Annotations only apply to the line right below them, even if it is not an assignment
+		annotations := pending
+		pending = nil
+
+		// Skip shell-only "set -a" / "set +a" lines
+		if line == "set -a" || line == "set +a" {
+			ignore("directive", line)
+			continue
+		}
+
+		// Handle "unset KEY [KEY...]" directives
+		if rest, ok := strings.CutPrefix(line, "unset "); ok {
+			for _, key := range strings.Fields(rest) {
+				key = options.keyMapping().apply(key)
+				if !isValidEnvKey(key) {
+					invalid(line, fmt.Errorf("invalid key format: %s", key))
+					continue
+				}
+				entries = append(entries, entry{file: file, line: lineNum, key: key, unset: true})
+				for id := range assigned {
+					if strings.HasPrefix(id, key+"\x00") {
+						delete(assigned, id)
+					}
+				}
+			}
+			continue
+		}
+
+		// Handle bare "export KEY [KEY...]" lines, which re-export variables in shell
+		if rest, ok := strings.CutPrefix(line, "export "); ok && !strings.ContainsAny(rest, options.Dialect.assign()) {
+			if !options.ExportFromOS {
+				ignore("directive", line)
+				continue
+			}
+			for _, key := range strings.Fields(rest) {
+				key = options.keyMapping().apply(key)
+				if !isValidEnvKey(key) {
+					invalid(line, fmt.Errorf("invalid key format: %s", key))
+					continue
+				}
+				// The OS value is taken literally, like a single-quoted one
+				if value, ok := os.LookupEnv(key); ok {
+					entries = append(entries, entry{file: file, line: lineNum, key: key, value: value, quote: '\''})
+				}
+			}
+			continue
+		}
+
+		// Parse key=value
+		var key, value string
+		var quote byte
+		var err error
+		switch {
+		case options.LineParser != nil:
+			key, value, err = parseCustomAssignment(line, options)
+			if err == nil && key == "" {
+				ignore("comment", "skipped by LineParser")
+				continue
+			}
+		case options.RawValues:
+			// Raw values are taken literally, like single-quoted ones
+			key, value, err = parseRawAssignment(text, options.keyMapping())
+			quote = '\''
+		case rawBlockStart(text, options.Dialect) >= 0:
+			// So are triple-quoted raw blocks, which may span lines
+			var lines int
+			key, value, lines, err = readRawBlock(text, scanner, options.Dialect, options.keyMapping())
+			physical += lines
+			quote = '\''
+		default:
+			dialect := options.Dialect
+			dialect.NoExpansion = dialect.NoExpansion || options.DisableExpansion // keeps "\$" as written
+			key, value, quote, err = parseAssignment(line, dialect, options.keyMapping())
+		}
+		if err != nil {
+			invalid(line, err)
+			continue
+		}
+
+		// Decode values annotated with "# @decode base64"; decoded values are taken literally
+		if encoding, ok := annotations["decode"]; ok {
+			value, err = decodeValue(encoding, value)
+			if err != nil {
+				invalid(line, err)
+				continue
+			}
+			quote = '\''
+		}
+
+		e := entry{file: file, line: lineNum, key: key, value: value, quote: quote, annotations: annotations}
+
+		// Apply the duplicate policy; ignored duplicates are kept for the Trace only
+		if options.DuplicatePolicy != DuplicateAllow {
+			id := key + "\x00" + e.annotations["group"]
+			prev, ok := assigned[id]
+			switch {
+			case !ok:
+				if assigned == nil {
+					assigned = make(map[string]int)
+				}
+				assigned[id] = len(entries)
+			case options.DuplicatePolicy == FirstWins:
+				e.ignored, e.reason = "duplicate", fmt.Sprintf("duplicate of line %d", entries[prev].line)
+			case options.DuplicatePolicy == LastWins:
+				entries[prev].ignored, entries[prev].reason = "duplicate", fmt.Sprintf("overridden on line %d", lineNum)
+				assigned[id] = len(entries)
+			default:
+				dupErrs = append(dupErrs, &ParseError{
+					File:   file,
+					Line:   lineNum,
+					Text:   line,
+					Reason: fmt.Sprintf("duplicate key %s, first assigned on line %d", key, entries[prev].line),
+				})
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, lineErrs, fmt.Errorf("read error: %w", err)
+	}
+	if len(dupErrs) > 0 {
+		return nil, lineErrs, errors.Join(dupErrs...)
+	}
+	if options.Trace == nil {
+		entries = slices.DeleteFunc(entries, func(e entry) bool { return e.ignored == "duplicate" })
+	}
+	return entries, lineErrs, nil
+}
+
+// loadFromReader reads environment variables from an io.Reader (e.g. file, buffer)
+// and applies them to the process environment. See readEntries for the accepted syntax.
 // Only sets a variable if:
 //   - Overwrite is true, OR
 //   - The variable is not already set in the environment.
 //
+// "unset KEY" removes a variable only if Overwrite is true or it was set earlier in the same file.
+//
 // Returns the number of successfully loaded variables and any critical read error.
-// Parsing errors do not stop execution but are logged when Debug = true.
+// Parsing errors do not stop execution but are logged when Debug = true,
+// unless Strict is set: then nothing is loaded and all invalid lines are returned.
 func loadFromReader(reader io.Reader, options *LoadOptions) (int, error) {
-	scanner := bufio.NewScanner(reader)
+	entries, lineErrs, err := loadEntries(reader, "", options)
+	if options.Strict && len(lineErrs) > 0 {
+		return 0, errors.Join(lineErrs...)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return applyEntries(entries, options)
+}
+
+// applyEntries sets and unsets the parsed entries in the process environment,
+// following the Overwrite and SkipEmpty policies described on loadFromReader.
+// Returns the number of variables set.
+func applyEntries(entries []entry, options *LoadOptions) (int, error) {
+	if frozen.Load() {
+		return 0, ErrFrozen
+	}
+
 	loaded := 0
+	setByFile := make(map[string]bool)
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	for _, e := range entries {
+		key, value := e.key, e.value
 
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
+		traced := TraceLine{File: e.file, Line: e.line, Kind: "assignment", Key: key}
+
+		switch {
+		case e.ignored != "":
+			traced.Kind, traced.Action, traced.Reason = e.ignored, "ignored", e.reason
+			if e.ignored == "invalid" {
+				traced.Action = "skipped"
+			}
+			options.Trace.add(traced)
+			continue
+		case e.extends != "":
+			// "#extends" is resolved before applying, see resolveExtends
+			traced.Kind, traced.Action, traced.Reason = "extends", "resolved", e.extends
+			options.Trace.add(traced)
 			continue
 		}
 
-		// Parse key=value
-		key, value, err := parseLine(line)
-		if err != nil {
+		if e.unset {
+			traced.Kind = "unset"
+			removed, err := unsetKey(key, setByFile, options)
+			if err != nil {
+				return loaded, err
+			}
+			traced.Action = "unset"
+			if !removed {
+				traced.Action, traced.Reason = "kept", "set outside this file"
+			}
+			options.Trace.add(traced)
+			continue
+		}
+
+		// Skip variables tagged for other process groups
+		if !e.inGroup(options.Group) {
 			if options.Debug {
-				fmt.Fprintf(os.Stderr, "quickenv: [DEBUG] skip invalid line %q: %v\n", line, err)
+				fmt.Fprintf(os.Stderr, "quickenv: [DEBUG] skip %s for group %q\n", key, e.annotations["group"])
 			}
+			traced.Action, traced.Reason = "skipped", "group "+e.annotations["group"]
+			options.Trace.add(traced)
+			continue
+		}
+
+		// Skip empty values if requested
+		if options.SkipEmpty && value == "" {
+			if options.Debug {
+				fmt.Fprintf(os.Stderr, "quickenv: [DEBUG] skip empty value for %s\n", key)
+			}
+			traced.Action, traced.Reason = "skipped", "empty value"
+			options.Trace.add(traced)
 			continue
 		}
 
 		// Set environment variable
+		traced.Action, traced.Reason = "kept", "already set in environment"
 		if options.Overwrite || os.Getenv(key) == "" {
 			if err := os.Setenv(key, value); err != nil {
 				return loaded, fmt.Errorf("failed to set %s: %w", key, err)
 			}
+			setByFile[key] = true
 			loaded++
+			traced.Action, traced.Reason = "set", ""
 
 			if options.Debug {
 				mask := "***"
@@ -177,85 +944,330 @@ func loadFromReader(reader io.Reader, options *LoadOptions) (int, error) {
 				fmt.Fprintf(os.Stderr, "quickenv: [DEBUG] set %s=%s\n", key, mask)
 			}
 		}
+		options.Trace.add(traced)
+	}
 
+	return loaded, nil
+}
+
+// assignedKeys returns the distinct keys assigned by entries, in first-seen order.
+func assignedKeys(entries []entry) []string {
+	keys := make([]string, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		if e.key != "" && !e.unset && !seen[e.key] {
+			seen[e.key] = true
+			keys = append(keys, e.key)
+		}
 	}
+	return keys
+}
 
-	if err := scanner.Err(); err != nil {
-		return loaded, fmt.Errorf("read error: %w", err)
+// annotations returns the annotations of the assigned keys for Result.Annotations.
+// A later annotated assignment of a key replaces the annotations of an earlier one,
+// and unsetting a key drops them.
+func annotations(entries []entry) map[string]map[string]string {
+	var byKey map[string]map[string]string
+	for _, e := range entries {
+		switch {
+		case e.ignored != "":
+		case e.unset:
+			delete(byKey, e.key)
+		case len(e.annotations) > 0:
+			if byKey == nil {
+				byKey = make(map[string]map[string]string)
+			}
+			byKey[e.key] = e.annotations
+		}
 	}
-	return loaded, nil
+	return byKey
+}
+
+// inGroup reports whether the entry applies to the process group: either it has no
+// "@group" annotation (shared) or group is one of the space-separated names it lists.
+func (e entry) inGroup(group string) bool {
+	groups, ok := e.annotations["group"]
+	return !ok || slices.Contains(strings.Fields(groups), group)
+}
+
+// unsetKey removes the variable from the environment and reports whether it did.
+// It is only removed if Overwrite is true or it was set earlier in the same file,
+// so pre-existing environment variables are protected by the same policy as assignments.
+func unsetKey(key string, setByFile map[string]bool, options *LoadOptions) (bool, error) {
+	if !options.Overwrite && !setByFile[key] {
+		return false, nil
+	}
+
+	if err := os.Unsetenv(key); err != nil {
+		return false, fmt.Errorf("failed to unset %s: %w", key, err)
+	}
+	delete(setByFile, key)
+
+	if options.Debug {
+		fmt.Fprintf(os.Stderr, "quickenv: [DEBUG] unset %s\n", key)
+	}
+	return true, nil
+}
+
+// isComment reports whether line starts with "#" or one of prefixes.
+func isComment(line string, prefixes []string) bool {
+	if strings.HasPrefix(line, "#") {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if prefix != "" && strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasContinuation reports whether line ends with an unescaped backslash,
+// i.e. an odd number of trailing backslashes.
+func hasContinuation(line string) bool {
+	n := len(line) - len(strings.TrimRight(line, `\`))
+	return n%2 == 1
+}
+
+// parseAnnotation parses a "# @name value" comment line, e.g. "# @group worker".
+// Returns the name, the trimmed value and true, or false if the comment is not an annotation.
+func parseAnnotation(line string) (string, string, bool) {
+	rest := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+	rest, ok := strings.CutPrefix(rest, "@")
+	if !ok {
+		return "", "", false
+	}
+
+	name, value, _ := strings.Cut(rest, " ")
+	if name == "" {
+		return "", "", false
+	}
+	return name, strings.TrimSpace(value), true
 }
 
+// decodeValue decodes a value annotated with "# @decode ENCODING". The only encoding
+// is "base64", standard alphabet, with or without padding, e.g. for service-account
+// JSON that would be mangled by quoting.
+func decodeValue(encoding, value string) (string, error) {
+	if encoding != "base64" {
+		return "", fmt.Errorf("unknown @decode encoding: %s", encoding)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		decoded, err = base64.RawStdEncoding.DecodeString(value)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid base64 value: %w", err)
+	}
+	return string(decoded), nil
+}
+
+// parseLine parses a single KEY=VALUE line.
 // Supports quoted values and the optional "export" prefix.
+// Escape sequences are interpreted in double-quoted values only, see unescapeValue.
+// A trailing unquoted "# comment" is removed, see stripInlineComment.
 // Only the first unquoted '=' is treated as delimiter.
 // Returns the key, value, and nil error on success.
 // Returns empty strings and an error if the line is invalid.
 func parseLine(line string) (string, string, error) {
+	key, value, _, err := parseAssignment(line, Dialect{}, keyMapping{})
+	return key, value, err
+}
+
+// parseAssignment is parseLine for the given dialect that also returns the quote
+// character that surrounded the value, or 0 if unquoted. The key is passed through
+// keys before it is validated.
+func parseAssignment(line string, dialect Dialect, keys keyMapping) (string, string, byte, error) {
 	// Handle export keyword
-	line = strings.TrimPrefix(line, "export")
+	line = trimExport(line)
 
-	// Find the first equals sign that's not in quotes
-	equalsIndex := -1
+	// Find the first assignment character that's not in quotes
+	assign, quotes := dialect.assign(), dialect.quotes()
+	equalsIndex, equalsLen := -1, 0
 	inQuotes := false
 	var quoteChar rune
 
 loop:
 	for i, char := range line {
 		switch {
-		case char == '"' || char == '\'':
+		case strings.ContainsRune(quotes, char):
 			if !inQuotes {
 				inQuotes = true
 				quoteChar = char
 			} else if char == quoteChar {
 				inQuotes = false
 			}
-		case char == '=' && !inQuotes:
-			equalsIndex = i
+		case !inQuotes && strings.ContainsRune(assign, char):
+			equalsIndex, equalsLen = i, utf8.RuneLen(char)
 			break loop
 		}
 	}
 
 	if equalsIndex == -1 {
-		return "", "", fmt.Errorf("invalid line format, missing equals sign")
+		return "", "", 0, fmt.Errorf("invalid line format, missing equals sign")
 	}
 
 	key := strings.TrimSpace(line[:equalsIndex])
-	value := strings.TrimSpace(line[equalsIndex+1:])
+	value := strings.TrimSpace(stripInlineComment(line[equalsIndex+equalsLen:], dialect))
 
 	// Validate key
 	if key == "" {
-		return "", "", fmt.Errorf("empty key")
+		return "", "", 0, fmt.Errorf("empty key")
 	}
 
+	key = keys.apply(key)
 	if !isValidEnvKey(key) {
-		return "", "", fmt.Errorf("invalid key format: %s", key)
+		return "", "", 0, fmt.Errorf("invalid key format: %s", key)
 	}
 
 	// Remove surrounding quotes from value
-	value = unquoteValue(value)
+	value, quote := unquote(value, quotes)
+
+	// Interpret escape sequences in double-quoted values
+	if quote == '"' && !dialect.LiteralQuotes {
+		value = unescapeValue(value, dialect.escapes(), !dialect.NoExpansion)
+	}
+
+	return key, value, quote, nil
+}
+
+// parseCustomAssignment parses an assignment line with options.LineParser and
+// validates the key it returns. An empty key with no error means the line is skipped.
+func parseCustomAssignment(line string, options *LoadOptions) (string, string, error) {
+	key, value, err := options.LineParser(line)
+	if err != nil || key == "" {
+		return "", "", err
+	}
 
+	key = options.keyMapping().apply(key)
+	if !isValidEnvKey(key) {
+		return "", "", fmt.Errorf("invalid key format: %s", key)
+	}
 	return key, value, nil
 }
 
+// parseRawAssignment parses a KEY=VALUE line for RawValues: the value is everything
+// after the first '=', byte for byte, without trimming, unquoting or stripping comments.
+func parseRawAssignment(line string, keys keyMapping) (string, string, error) {
+	before, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return "", "", fmt.Errorf("invalid line format, missing equals sign")
+	}
+
+	key := strings.TrimSpace(trimExport(strings.TrimSpace(before)))
+	if key == "" {
+		return "", "", fmt.Errorf("empty key")
+	}
+	key = keys.apply(key)
+	if !isValidEnvKey(key) {
+		return "", "", fmt.Errorf("invalid key format: %s", key)
+	}
+	return key, value, nil
+}
+
+// trimExport removes a leading "export" keyword followed by whitespace from line,
+// so a key that merely starts with it, like "exporter", is kept whole.
+func trimExport(line string) string {
+	if rest, ok := strings.CutPrefix(line, "export"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+		return rest
+	}
+	return line
+}
+
+// rawBlockStart returns the index of the `"""` opening a KEY="""...""" raw block
+// in line, or -1 if the value doesn't start with one.
+func rawBlockStart(line string, dialect Dialect) int {
+	i := strings.IndexAny(line, dialect.assign())
+	if i < 0 {
+		return -1
+	}
+	_, size := utf8.DecodeRuneInString(line[i:])
+	rest := strings.TrimLeft(line[i+size:], " \t")
+	if !strings.HasPrefix(rest, `"""`) {
+		return -1
+	}
+	return len(line) - len(rest)
+}
+
+// readRawBlock parses a KEY="""...""" raw block starting on line, reading further
+// lines from scanner until the closing `"""`, and returns the number of lines read.
+// The value is taken verbatim: no escape sequences, expansion or comments, and line
+// breaks are kept. A line break right after the opening `"""` is dropped, so the
+// block can start on its own line. Only a comment may follow the closing `"""`.
+func readRawBlock(line string, scanner *bufio.Scanner, dialect Dialect, keys keyMapping) (string, string, int, error) {
+	start := rawBlockStart(line, dialect)
+	key, _, _, err := parseAssignment(strings.TrimSpace(line[:start]), dialect, keys)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	value := line[start+3:]
+	end := strings.Index(value, `"""`)
+	lines := 0
+	for end < 0 {
+		if !scanner.Scan() {
+			return "", "", lines, fmt.Errorf(`unterminated """ block`)
+		}
+		lines++
+		next := trimLineEnding(scanner.Text())
+		if i := strings.Index(next, `"""`); i >= 0 {
+			end = len(value) + 1 + i
+		}
+		value += "\n" + next
+	}
+
+	if rest := strings.TrimSpace(value[end+3:]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", "", lines, fmt.Errorf(`unexpected text after closing """: %s`, rest)
+	}
+	return key, strings.TrimPrefix(value[:end], "\n"), lines, nil
+}
+
+// keyMapping turns keys from other tooling into environment variable names before
+// they are validated, see LoadOptions.NormalizeKeys and LoadOptions.KeyDelimiter.
+type keyMapping struct {
+	normalize bool
+	delimiter string
+}
+
+// keyMapping returns the key mapping configured by o.
+func (o *LoadOptions) keyMapping() keyMapping {
+	return keyMapping{normalize: o.NormalizeKeys, delimiter: o.KeyDelimiter}
+}
+
+// apply maps key; it is returned unchanged if no mapping is configured.
+func (m keyMapping) apply(key string) string {
+	if m.delimiter != "" {
+		key = strings.ToUpper(strings.ReplaceAll(key, m.delimiter, "_"))
+	}
+	if m.normalize {
+		key = normalizeKey(key)
+	}
+	return key
+}
+
+// normalizeKey turns a key from other tooling into an environment variable name for
+// NormalizeKeys: it is upper-cased and '-' and '.' become '_', so "db-port" is DB_PORT.
+func normalizeKey(key string) string {
+	return strings.ToUpper(keySeparators.Replace(key))
+}
+
+// keySeparators replaces the separators that normalizeKey turns into '_'.
+var keySeparators = strings.NewReplacer("-", "_", ".", "_")
+
 // isValidEnvKey checks if a string is a valid environment variable name.
 // Rules:
 //   - Must not be empty
-//   - First character must be a letter or underscore
-//   - Subsequent characters may be letters, digits, or underscores
+//   - First character must be an ASCII letter or underscore
+//   - Subsequent characters may be ASCII letters, digits, or underscores
 func isValidEnvKey(key string) bool {
 	if key == "" {
 		return false
 	}
 
-	// First character must be letter or underscore
-	firstChar := rune(key[0])
-	if !unicode.IsLetter(firstChar) && firstChar != '_' {
-		return false
-	}
-
-	// Rest can be letters, numbers, or underscores
-	for _, char := range key[1:] {
-		if !unicode.IsLetter(char) && !unicode.IsDigit(char) && char != '_' {
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
 			return false
 		}
 	}
@@ -266,15 +1278,127 @@ func isValidEnvKey(key string) bool {
 // unquoteValue strips surrounding single or double quotes if both are present and matching.
 // Returns the original string otherwise.
 func unquoteValue(value string) string {
+	value, _ = unquote(value, Dialect{}.quotes())
+	return value
+}
+
+// unquote is unquoteValue for the given quote characters that also returns the
+// stripped quote character, or 0 if none.
+func unquote(value, quotes string) (string, byte) {
 	if len(value) >= 2 {
 		first, last := value[0], value[len(value)-1]
-		if (first == '"' && last == '"') || (first == '\'' && last == '\'') {
-			return value[1 : len(value)-1]
+		if first == last && strings.IndexByte(quotes, first) >= 0 {
+			return value[1 : len(value)-1], first
 		}
 	}
+	return value, 0
+}
+
+// stripInlineComment removes a trailing "# comment" from a raw value.
+// For unquoted values a '#' starts a comment only when preceded by whitespace and
+// outside quotes, so "KEY=a#b" and KEY=--name="x # y" keep their values. For quoted
+// values only a '#' after the closing quote starts a comment, so '#' inside quotes
+// is preserved. With Dialect.LiteralQuotes backslashes don't escape quotes.
+func stripInlineComment(value string, dialect Dialect) string {
+	trimmed := strings.TrimLeft(value, " \t")
+	if trimmed == "" {
+		return value
+	}
+	quotes := dialect.quotes()
+
+	// Quoted value: look for the closing quote, skipping \" in double quotes
+	if q := trimmed[0]; strings.IndexByte(quotes, q) >= 0 {
+		for i := 1; i < len(trimmed); i++ {
+			if q == '"' && trimmed[i] == '\\' && !dialect.LiteralQuotes {
+				i++
+				continue
+			}
+			if trimmed[i] == q {
+				if strings.HasPrefix(strings.TrimSpace(trimmed[i+1:]), "#") {
+					return trimmed[:i+1]
+				}
+				if !dialect.LiteralQuotes {
+					return value
+				}
+			}
+		}
+		return value
+	}
+
+	// Unquoted value: "KEY= # comment" is empty
+	if trimmed[0] == '#' && len(trimmed) < len(value) {
+		return ""
+	}
+	if i := commentStart(trimmed, quotes, true); i != -1 {
+		return trimmed[:i]
+	}
 	return value
 }
 
+// commentStart returns the index of the '#' that starts an inline comment in the
+// unquoted value, or -1. With honorQuotes a '#' between quotes is part of the value,
+// unless a quote is never closed: an apostrophe like in "it's # comment" is not a quote.
+func commentStart(value, quotes string, honorQuotes bool) int {
+	var inQuote byte
+	for i := 1; i < len(value); i++ {
+		switch {
+		case !honorQuotes:
+		case inQuote == 0 && strings.IndexByte(quotes, value[i]) >= 0:
+			inQuote = value[i]
+			continue
+		case value[i] == inQuote:
+			inQuote = 0
+			continue
+		}
+		if inQuote == 0 && value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			return i
+		}
+	}
+	if inQuote != 0 {
+		return commentStart(value, quotes, false)
+	}
+	return -1
+}
+
+// unescapeValue interprets the escape sequences for the characters in escapes, see
+// Dialect.Escapes, in a double-quoted value: \n, \r and \t become control characters and
+// any other escaped character stands for itself, e.g. \" and \\. Other backslashes are kept as is.
+// If the value is expanded afterwards, "\$" becomes "$$", which expansion reads as a
+// literal "$", so an escaped backslash before a reference like "\\$HOME" is not
+// mistaken for an escaped "$" later.
+func unescapeValue(value, escapes string, expand bool) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var b strings.Builder
+	b.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		if expand && value[i] == '\\' && i+1 < len(value) && value[i+1] == '$' {
+			b.WriteString("$$")
+			i++
+			continue
+		}
+		if value[i] != '\\' || i+1 == len(value) || strings.IndexByte(escapes, value[i+1]) < 0 {
+			b.WriteByte(value[i])
+			continue
+		}
+
+		switch value[i+1] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		default:
+			b.WriteByte(value[i+1])
+		}
+		i++
+	}
+	return b.String()
+}
+
 // GetEnv returns the value of the environmnet variable named by the key.
 // It returns the defaultValue if the variable is not present.
 func GetEnv(key, defaultValue string) string {
@@ -285,9 +1409,30 @@ func GetEnv(key, defaultValue string) string {
 }
 
 // GetEnvOrPanic returns the value of the environment variable or panics if not set.
+// The error is passed to the fatal handler first, see SetFatalHandler.
 func GetEnvOrPanic(key string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
-	panic(fmt.Sprintf("quickenv: required environment variable %s is not set", key))
+	fatal(fmt.Errorf("quickenv: required environment variable %s is not set", key))
+	return ""
+}
+
+// lazyLoadOnce guards the one-time Load performed by LookupOrLoad.
+var lazyLoadOnce sync.Once
+
+// LookupOrLoad returns the value of the environment variable named by the key.
+// On the first miss it loads the default env file once (see DefaultLoadOptions)
+// and looks the key up again before falling back to the defaultValue.
+// Load errors are ignored, so a missing env file simply yields the default.
+func LookupOrLoad(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	lazyLoadOnce.Do(func() {
+		_, _ = Load()
+	})
+
+	return GetEnv(key, defaultValue)
 }
@@ -1,7 +1,20 @@
 package quickenv
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf16"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -28,6 +41,20 @@ func TestParseLine(t *testing.T) {
 			wantVal: "abc123",
 			wantErr: false,
 		},
+		{
+			name:    "key starting with export",
+			input:   "exporter=1",
+			wantKey: "exporter",
+			wantVal: "1",
+			wantErr: false,
+		},
+		{
+			name:    "export with tab",
+			input:   "export\tAPI_KEY=abc123",
+			wantKey: "API_KEY",
+			wantVal: "abc123",
+			wantErr: false,
+		},
 		{
 			name:    "value in double quotes",
 			input:   `NAME="Alex Edwards"`,
@@ -106,6 +133,97 @@ func TestParseLine(t *testing.T) {
 			wantVal: "=John=Doe",
 			wantErr: false,
 		},
+		{
+			name:    "escape sequences in double quotes",
+			input:   `MSG="line1\nline2\ttab\r\"quoted\" back\\slash"`,
+			wantKey: "MSG",
+			wantVal: "line1\nline2\ttab\r\"quoted\" back\\slash",
+			wantErr: false,
+		},
+		{
+			name:    "unknown escape kept",
+			input:   `PATTERN="\d+"`,
+			wantKey: "PATTERN",
+			wantVal: `\d+`,
+			wantErr: false,
+		},
+		{
+			name:    "single quotes stay literal",
+			input:   `MSG='line1\nline2'`,
+			wantKey: "MSG",
+			wantVal: `line1\nline2`,
+			wantErr: false,
+		},
+		{
+			name:    "unquoted stays literal",
+			input:   `MSG=line1\nline2`,
+			wantKey: "MSG",
+			wantVal: `line1\nline2`,
+			wantErr: false,
+		},
+		{
+			name:    "inline comment",
+			input:   "PORT=8080 # local dev port",
+			wantKey: "PORT",
+			wantVal: "8080",
+			wantErr: false,
+		},
+		{
+			name:    "inline comment after quoted value",
+			input:   `NAME="John # not a comment" # comment`,
+			wantKey: "NAME",
+			wantVal: "John # not a comment",
+			wantErr: false,
+		},
+		{
+			name:    "inline comment after single-quoted value",
+			input:   `NAME='a#b'	# comment`,
+			wantKey: "NAME",
+			wantVal: "a#b",
+			wantErr: false,
+		},
+		{
+			name:    "hash without preceding space",
+			input:   "URL=http://host/page#anchor",
+			wantKey: "URL",
+			wantVal: "http://host/page#anchor",
+			wantErr: false,
+		},
+		{
+			name:    "only inline comment",
+			input:   "EMPTY= # nothing here",
+			wantKey: "EMPTY",
+			wantVal: "",
+			wantErr: false,
+		},
+		{
+			name:    "hash inside quotes of an unquoted value",
+			input:   `FLAG=--name="x # y"`,
+			wantKey: "FLAG",
+			wantVal: `--name="x # y"`,
+			wantErr: false,
+		},
+		{
+			name:    "apostrophe in an unquoted value before inline comment",
+			input:   "MSG=it's fine # comment",
+			wantKey: "MSG",
+			wantVal: "it's fine",
+			wantErr: false,
+		},
+		{
+			name:    "value starting with hash",
+			input:   "COLOR=#ff0000",
+			wantKey: "COLOR",
+			wantVal: "#ff0000",
+			wantErr: false,
+		},
+		{
+			name:    "escaped quote before inline comment",
+			input:   `MSG="say \"hi\"" # greeting`,
+			wantKey: "MSG",
+			wantVal: `say "hi"`,
+			wantErr: false,
+		},
 		{
 			name:    "key in single quotes containing = should be invalid",
 			input:   "'NAME='=John=Doe",
@@ -154,7 +272,7 @@ func TestUnquoteValue(t *testing.T) {
 
 func TestIsValidEnvKey(t *testing.T) {
 	valid := []string{"PORT", "API_KEY", "DEBUG", "A", "_INTERNAL", "Var1"}
-	invalid := []string{"", "123", "-", "my-var", "кошка", " ", "a b", ".hidden", ""}
+	invalid := []string{"", "123", "-", "my-var", "кошка", "ÄKEY", "KEY_Ä", "🔑", " ", "a b", ".hidden", ""}
 
 	for _, key := range valid {
 		t.Run("valid_"+key, func(t *testing.T) {
@@ -168,3 +286,1231 @@ func TestIsValidEnvKey(t *testing.T) {
 		})
 	}
 }
+
+func TestLookupOrLoad(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, ".env"), []byte("LAZY_KEY=from_file\n"), 0o600)
+	assert.NoError(t, err)
+
+	t.Chdir(dir)
+	unsetEnv(t, "LAZY_KEY", "LAZY_MISSING")
+	lazyLoadOnce = sync.Once{}
+
+	assert.Equal(t, "from_file", LookupOrLoad("LAZY_KEY", "fallback"))
+	assert.Equal(t, "fallback", LookupOrLoad("LAZY_MISSING", "fallback"))
+}
+
+// unsetEnv clears the given variables for the duration of the test.
+func unsetEnv(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func TestLoadFromReaderShellDirectives(t *testing.T) {
+	unsetEnv(t, "SH_A", "SH_B", "SH_EXISTING")
+	t.Setenv("SH_EXISTING", "keep")
+
+	input := "set -a\nSH_A=1\nSH_B=2\nunset SH_A SH_EXISTING\nset +a\n"
+	count, err := loadFromReader(strings.NewReader(input), DefaultLoadOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	_, ok := os.LookupEnv("SH_A")
+	assert.False(t, ok)
+	assert.Equal(t, "2", os.Getenv("SH_B"))
+	assert.Equal(t, "keep", os.Getenv("SH_EXISTING"))
+
+	_, err = loadFromReader(strings.NewReader("unset SH_EXISTING\n"), &LoadOptions{Overwrite: true})
+	assert.NoError(t, err)
+	_, ok = os.LookupEnv("SH_EXISTING")
+	assert.False(t, ok)
+}
+
+func TestLoadFromReaderBareExport(t *testing.T) {
+	unsetEnv(t, "EXP_HOME", "EXP_BIN")
+	t.Setenv("EXP_HOME", "/opt/app")
+
+	// Re-export lines are ignored, not reported as invalid
+	input := "export EXP_HOME\nEXP_BIN=${EXP_HOME}/bin\n"
+	count, err := loadFromReader(strings.NewReader(input), &LoadOptions{Strict: true, ExpandFileOnly: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, "/bin", os.Getenv("EXP_BIN"))
+
+	os.Unsetenv("EXP_BIN")
+	count, err = loadFromReader(strings.NewReader(input+"export EXP_MISSING\n"), &LoadOptions{Strict: true, ExpandFileOnly: true, ExportFromOS: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, "/opt/app/bin", os.Getenv("EXP_BIN"))
+
+	_, err = loadFromReader(strings.NewReader("export 1BAD\n"), &LoadOptions{Strict: true, ExportFromOS: true})
+	assert.ErrorContains(t, err, "line 1: invalid key format: 1BAD")
+}
+
+func TestSetFatalHandler(t *testing.T) {
+	unsetEnv(t, "FATAL_MISSING")
+
+	var got error
+	SetFatalHandler(func(err error) { got = err })
+	t.Cleanup(func() { SetFatalHandler(nil) })
+
+	assert.PanicsWithValue(t, "quickenv: required environment variable FATAL_MISSING is not set", func() {
+		GetEnvOrPanic("FATAL_MISSING")
+	})
+	assert.EqualError(t, got, "quickenv: required environment variable FATAL_MISSING is not set")
+
+	got = nil
+	assert.Panics(t, func() {
+		MustLoad(&LoadOptions{Pathname: "does-not-exist.env", MaxLevels: 1})
+	})
+	assert.ErrorContains(t, got, "env file not found")
+	assert.NotContains(t, got.Error(), "quickenv: quickenv:", "Load errors are already prefixed")
+}
+
+func TestLoadFromReaderSkipEmpty(t *testing.T) {
+	unsetEnv(t, "SKIP_EMPTY", "SKIP_QUOTED", "SKIP_FULL")
+
+	input := "SKIP_EMPTY=\nSKIP_QUOTED=\"\"\nSKIP_FULL=value\n"
+	count, err := loadFromReader(strings.NewReader(input), &LoadOptions{SkipEmpty: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	_, ok := os.LookupEnv("SKIP_EMPTY")
+	assert.False(t, ok)
+	_, ok = os.LookupEnv("SKIP_QUOTED")
+	assert.False(t, ok)
+	assert.Equal(t, "value", os.Getenv("SKIP_FULL"))
+}
+
+func TestStaleSince(t *testing.T) {
+	unsetEnv(t, "STALE_KEY")
+	path := filepath.Join(t.TempDir(), "stale.env")
+	assert.NoError(t, os.WriteFile(path, []byte("STALE_KEY=1\n"), 0o600))
+
+	_, err := Load(&LoadOptions{Pathname: path})
+	assert.NoError(t, err)
+
+	_, stale := StaleSince()
+	assert.False(t, stale)
+
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(path, later, later))
+	_, stale = StaleSince()
+	assert.True(t, stale)
+
+	_, err = Load(&LoadOptions{Pathname: path})
+	assert.NoError(t, err)
+	_, stale = StaleSince()
+	assert.False(t, stale)
+}
+
+func TestStaleSinceIncludes(t *testing.T) {
+	unsetEnv(t, "STALE_BASE", "STALE_KEY")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "stale.env")
+	base := filepath.Join(dir, "base.env")
+	assert.NoError(t, os.WriteFile(base, []byte("STALE_BASE=1\n"), 0o600))
+	assert.NoError(t, os.WriteFile(path, []byte("#extends base.env\nSTALE_KEY=1\n"), 0o600))
+
+	// A relative path keeps pointing at the loaded file after a directory change
+	t.Chdir(dir)
+	_, err := Load(&LoadOptions{Pathname: "stale.env"})
+	assert.NoError(t, err)
+	t.Chdir(t.TempDir())
+
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(path, later, later))
+	_, stale := StaleSince()
+	assert.True(t, stale)
+
+	// Editing an extended file makes the configuration stale too
+	_, err = Load(&LoadOptions{Pathname: path})
+	assert.NoError(t, err)
+	_, stale = StaleSince()
+	assert.False(t, stale)
+	assert.NoError(t, os.Chtimes(base, later, later))
+	_, stale = StaleSince()
+	assert.True(t, stale)
+}
+
+func TestVerify(t *testing.T) {
+	unsetEnv(t, "VERIFY_OK")
+	dir := t.TempDir()
+
+	good := filepath.Join(dir, "good.env")
+	assert.NoError(t, os.WriteFile(good, []byte("# comment\nVERIFY_OK=1\nunset VERIFY_OK\n"), 0o600))
+	assert.NoError(t, Verify(&LoadOptions{Pathname: good}))
+
+	_, ok := os.LookupEnv("VERIFY_OK")
+	assert.False(t, ok, "Verify must not set variables")
+
+	bad := filepath.Join(dir, "bad.env")
+	assert.NoError(t, os.WriteFile(bad, []byte("VERIFY_OK=1\njustvalue\n1BAD=x\n"), 0o600))
+	err := Verify(&LoadOptions{Pathname: bad})
+	assert.ErrorContains(t, err, "line 2: invalid line format")
+	assert.ErrorContains(t, err, "line 3: invalid key format: 1BAD")
+
+	assert.Error(t, Verify(&LoadOptions{Pathname: filepath.Join(dir, "missing.env"), MaxLevels: 1}))
+}
+
+func TestConfigHash(t *testing.T) {
+	unsetEnv(t, "HASH_A", "HASH_B")
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.env")
+	second := filepath.Join(dir, "second.env")
+	assert.NoError(t, os.WriteFile(first, []byte("HASH_A=1\nHASH_B=secret\n"), 0o600))
+	assert.NoError(t, os.WriteFile(second, []byte("HASH_B=secret\nHASH_A=1\n"), 0o600))
+
+	_, err := Load(&LoadOptions{Pathname: first, Overwrite: true})
+	assert.NoError(t, err)
+	hash := ConfigHash()
+
+	// Known answer computed with coreutils from the documented algorithm:
+	//   printf 'HASH_A=%s\nHASH_B=%s\n' "$(printf 1 | sha256sum | cut -d' ' -f1)" \
+	//     "$(printf secret | sha256sum | cut -d' ' -f1)" | sha256sum
+	assert.Equal(t, "b579539db9da5d0b4ccdd7607b1e96b6211f3d5c6f433ccb72dc2169c4f57c7d", hash)
+
+	_, err = Load(&LoadOptions{Pathname: second, Overwrite: true})
+	assert.NoError(t, err)
+	assert.Equal(t, hash, ConfigHash(), "key order must not matter")
+
+	t.Setenv("HASH_B", "changed")
+	assert.NotEqual(t, hash, ConfigHash())
+}
+
+func TestParse(t *testing.T) {
+	unsetEnv(t, "PARSE_HOST", "PARSE_URL", "PARSE_GONE")
+
+	input := "PARSE_HOST=db.local\nPARSE_URL=\"postgres://${PARSE_HOST}/app\"\nPARSE_GONE=1\nunset PARSE_GONE\n"
+	vars, err := Parse(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"PARSE_HOST": "db.local", "PARSE_URL": "postgres://db.local/app"}, vars)
+	_, ok := os.LookupEnv("PARSE_HOST")
+	assert.False(t, ok, "Parse must not set variables")
+
+	vars, err = Parse(strings.NewReader("PARSE_A=1\nbad line\nPARSE_B=2\n1BAD=3\n"))
+	assert.Nil(t, vars)
+	var parseErr *ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 2, parseErr.Line)
+	assert.ErrorContains(t, err, "line 4: invalid key format: 1BAD")
+
+	vars, err = Parse(strings.NewReader("PARSE_A=\n# @group worker\nPARSE_B=2\n"), &LoadOptions{SkipEmpty: true, Group: "worker"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"PARSE_B": "2"}, vars)
+}
+
+func TestLoadHooks(t *testing.T) {
+	unsetEnv(t, "HOOK_A", "HOOK_B")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hooks.env")
+	assert.NoError(t, os.WriteFile(path, []byte("HOOK_A=1\nHOOK_B=2\n"), 0o600))
+
+	var got Result
+	count, err := Load(&LoadOptions{
+		Pathname: "placeholder.env",
+		PreLoad: func(o *LoadOptions) error {
+			o.Pathname = path
+			return nil
+		},
+		PostLoad: func(r Result) error {
+			got = r
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, Result{
+		Path:    path,
+		Loaded:  2,
+		Keys:    []string{"HOOK_A", "HOOK_B"},
+		Sources: map[string]string{"HOOK_A": path + ":1", "HOOK_B": path + ":2"},
+	}, got)
+
+	_, err = Load(&LoadOptions{
+		Pathname: path,
+		PostLoad: func(Result) error { return assert.AnError },
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+
+	_, err = Load(&LoadOptions{
+		PreLoad: func(*LoadOptions) error { return assert.AnError },
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+func TestLoadAnnotations(t *testing.T) {
+	unsetEnv(t, "META_PORT", "META_TIMEOUT", "META_NAME", "META_GONE")
+	path := filepath.Join(t.TempDir(), "meta.env")
+	content := "# @type int\n# @required\nMETA_PORT=8080\n\n# @type duration\nMETA_TIMEOUT=5s\nMETA_NAME=web\n# @type bool\nMETA_GONE=1\nunset META_GONE\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	var got Result
+	_, err := Load(&LoadOptions{Pathname: path, PostLoad: func(r Result) error {
+		got = r
+		return nil
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		"META_PORT":    {"type": "int", "required": ""},
+		"META_TIMEOUT": {"type": "duration"},
+	}, got.Annotations)
+}
+
+func TestWriteJSONSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env.example")
+	content := strings.Join([]string{
+		"# @type int",
+		"# @required",
+		"SCHEMA_PORT=8080",
+		"# @enum debug info warn",
+		"SCHEMA_LEVEL=info",
+		"# @type duration",
+		"SCHEMA_TIMEOUT=5s",
+		"# @required",
+		"SCHEMA_PASSWORD=${SCHEMA_PASSWORD:?set a password}",
+		"SCHEMA_GONE=1",
+		"unset SCHEMA_GONE",
+	}, "\n")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	var b strings.Builder
+	assert.NoError(t, WriteJSONSchema(&b, &LoadOptions{Pathname: path}))
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"properties": {
+			"SCHEMA_LEVEL": {"type": "string", "enum": ["debug", "info", "warn"]},
+			"SCHEMA_PASSWORD": {"type": "string"},
+			"SCHEMA_PORT": {"type": "integer"},
+			"SCHEMA_TIMEOUT": {"type": "string", "pattern": "^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"}
+		},
+		"required": ["SCHEMA_PASSWORD", "SCHEMA_PORT"]
+	}`, b.String())
+	_, ok := os.LookupEnv("SCHEMA_PORT")
+	assert.False(t, ok, "nothing is set")
+
+	assert.NoError(t, os.WriteFile(path, []byte("# @type uuid\nSCHEMA_ID=1\n"), 0o600))
+	err := WriteJSONSchema(&b, &LoadOptions{Pathname: path})
+	assert.EqualError(t, err, "quickenv: SCHEMA_ID: unknown @type: uuid")
+}
+
+func TestLoadExtends(t *testing.T) {
+	unsetEnv(t, "WS_SHARED", "WS_OVERRIDE", "WS_SERVICE", "WS_ROOT")
+	root := t.TempDir()
+	service := filepath.Join(root, "services", "api")
+	assert.NoError(t, os.MkdirAll(service, 0o755))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(root, ".env.root"), []byte("WS_ROOT=root\n"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, ".env.workspace"),
+		[]byte("#extends .env.root\nWS_SHARED=shared\nWS_OVERRIDE=base\n"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(service, ".env"),
+		[]byte("#extends ../../.env.workspace\nWS_OVERRIDE=service\nWS_SERVICE=api\n"), 0o600))
+
+	count, err := Load(&LoadOptions{Pathname: filepath.Join(service, ".env"), Root: root})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+	assert.Equal(t, "root", os.Getenv("WS_ROOT"))
+	assert.Equal(t, "shared", os.Getenv("WS_SHARED"))
+	assert.Equal(t, "service", os.Getenv("WS_OVERRIDE"))
+	assert.Equal(t, "api", os.Getenv("WS_SERVICE"))
+}
+
+func TestLoadExtendsCollisions(t *testing.T) {
+	unsetEnv(t, "COL_PORT", "COL_SAME", "COL_DROPPED")
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.env")
+	path := filepath.Join(dir, ".env")
+	assert.NoError(t, os.WriteFile(base, []byte("COL_PORT=80\nCOL_SAME=1\nCOL_DROPPED=x\n"), 0o600))
+	assert.NoError(t, os.WriteFile(path, []byte("#extends base.env\nCOL_PORT=8080\nCOL_SAME=1\nunset COL_DROPPED\n"), 0o600))
+
+	var result Result
+	_, err := Load(&LoadOptions{Pathname: path, PostLoad: func(r Result) error { result = r; return nil }})
+	assert.NoError(t, err)
+	assert.Equal(t, []Collision{{Key: "COL_PORT", Winner: path + ":2", Losers: []string{base + ":1"}}}, result.Collisions)
+	assert.Equal(t, "8080", os.Getenv("COL_PORT"))
+
+	warnings := captureWarnings(t)
+	unsetEnv(t, "COL_PORT", "COL_SAME")
+	_, err = Load(&LoadOptions{Pathname: path, Collisions: CollisionWarn})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key collision: COL_PORT: " + path + ":2 overrides [" + base + ":1]"}, *warnings)
+
+	unsetEnv(t, "COL_PORT", "COL_SAME")
+	_, err = Load(&LoadOptions{Pathname: path, Collisions: CollisionError})
+	assert.ErrorContains(t, err, "quickenv: key collision: COL_PORT")
+	_, ok := os.LookupEnv("COL_SAME")
+	assert.False(t, ok)
+
+	// The preflight checks reject what Load rejects
+	assert.NoError(t, Verify(&LoadOptions{Pathname: path}))
+	assert.ErrorContains(t, Verify(&LoadOptions{Pathname: path, Collisions: CollisionError}), "quickenv: key collision: COL_PORT")
+
+	results, err := ValidateAll(path, &LoadOptions{Collisions: CollisionError})
+	assert.NoError(t, err)
+	assert.Len(t, results[path], 1)
+	assert.ErrorContains(t, results[path][0], "quickenv: key collision: COL_PORT")
+
+	env, err := EnvForDir(dir, &LoadOptions{MaxLevels: 1, Collisions: CollisionError})
+	assert.Nil(t, env)
+	assert.ErrorContains(t, err, "quickenv: key collision: COL_PORT")
+	env, err = EnvForDir(dir, &LoadOptions{MaxLevels: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "8080", env["COL_PORT"])
+}
+
+func TestLoadExtra(t *testing.T) {
+	unsetEnv(t, "EXTRA_HOST", "EXTRA_URL", "EXTRA_VERSION", "EXTRA_PORT")
+	path := filepath.Join(t.TempDir(), "extra.env")
+	assert.NoError(t, os.WriteFile(path, []byte("EXTRA_URL=http://${EXTRA_HOST}:${EXTRA_PORT}\nEXTRA_PORT=8080\n"), 0o600))
+	extra := map[string]string{"EXTRA_HOST": "web-1", "EXTRA_PORT": "9090", "EXTRA_VERSION": "v1.2.3 $notexpanded"}
+
+	var result Result
+	_, err := Load(&LoadOptions{Pathname: path, Extra: extra, PostLoad: func(r Result) error { result = r; return nil }})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://web-1:", os.Getenv("EXTRA_URL"), "the file's EXTRA_PORT wins but is assigned later")
+	assert.Equal(t, "8080", os.Getenv("EXTRA_PORT"))
+	assert.Equal(t, "v1.2.3 $notexpanded", os.Getenv("EXTRA_VERSION"))
+	assert.Equal(t, "Extra", result.Sources["EXTRA_HOST"])
+
+	unsetEnv(t, "EXTRA_HOST", "EXTRA_URL", "EXTRA_VERSION", "EXTRA_PORT")
+	_, err = Load(&LoadOptions{Pathname: path, Extra: extra, ExtraOverrides: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://web-1:9090", os.Getenv("EXTRA_URL"))
+	assert.Equal(t, "9090", os.Getenv("EXTRA_PORT"))
+
+	_, err = Load(&LoadOptions{Pathname: path, Extra: map[string]string{"bad key": "x"}})
+	assert.ErrorContains(t, err, "invalid Extra key: bad key")
+}
+
+func TestResultSummary(t *testing.T) {
+	unsetEnv(t, "SUM_HOST", "SUM_API_TOKEN", "SUM_WORKER")
+	t.Setenv("SUM_USER", "admin")
+	path := filepath.Join(t.TempDir(), "summary.env")
+	assert.NoError(t, os.WriteFile(path, []byte("SUM_HOST=db.local\nSUM_API_TOKEN=s3cret\nSUM_USER=app\n# @group worker\nSUM_WORKER=1\n"), 0o600))
+
+	var result Result
+	_, err := Load(&LoadOptions{Pathname: path, PostLoad: func(r Result) error { result = r; return nil }})
+	assert.NoError(t, err)
+
+	var b strings.Builder
+	assert.NoError(t, result.Summary(&b, true))
+	assert.Equal(t, strings.Join([]string{
+		"KEY            VALUE     SOURCE",
+		"SUM_HOST       db.local  " + path + ":1",
+		"SUM_API_TOKEN  ***       " + path + ":2",
+		"SUM_USER       admin     environment",
+		"SUM_WORKER               skipped: group worker",
+		"",
+	}, "\n"), b.String())
+
+	b.Reset()
+	assert.NoError(t, result.Summary(&b, false))
+	assert.Contains(t, b.String(), "s3cret")
+}
+
+func TestLoadPlatformOverlay(t *testing.T) {
+	unsetEnv(t, "PLAT_SOCKET", "PLAT_SHARED", "PLAT_ONLY_BASE")
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".env")
+	assert.NoError(t, os.WriteFile(path, []byte("PLAT_SOCKET=/var/run/app.sock\nPLAT_SHARED=1\nPLAT_ONLY_BASE=x\n"), 0o600))
+	assert.NoError(t, os.WriteFile(path+"."+runtime.GOOS, []byte("PLAT_SOCKET=platform.sock\nunset PLAT_ONLY_BASE\n"), 0o600))
+
+	var result Result
+	_, err := Load(&LoadOptions{Pathname: path, PlatformOverlay: true, Overwrite: true, PostLoad: func(r Result) error { result = r; return nil }})
+	assert.NoError(t, err)
+	assert.Equal(t, "platform.sock", os.Getenv("PLAT_SOCKET"))
+	assert.Equal(t, []Collision{{Key: "PLAT_SOCKET", Winner: path + "." + runtime.GOOS + ":1", Losers: []string{path + ":1"}}}, result.Collisions)
+	assert.Equal(t, "1", os.Getenv("PLAT_SHARED"))
+	_, ok := os.LookupEnv("PLAT_ONLY_BASE")
+	assert.False(t, ok)
+
+	unsetEnv(t, "PLAT_SOCKET", "PLAT_SHARED")
+	_, err = Load(&LoadOptions{Pathname: path})
+	assert.NoError(t, err)
+	assert.Equal(t, "/var/run/app.sock", os.Getenv("PLAT_SOCKET"), "overlays are opt-in")
+
+	// A missing overlay is fine
+	other := filepath.Join(dir, "other.env")
+	assert.NoError(t, os.WriteFile(other, []byte("PLAT_SHARED=2\n"), 0o600))
+	_, err = Load(&LoadOptions{Pathname: other, PlatformOverlay: true})
+	assert.NoError(t, err)
+
+	// ValidateAll checks the overlay like Verify does
+	assert.NoError(t, os.WriteFile(other+"."+runtime.GOOS, []byte("not valid\n"), 0o600))
+	assert.ErrorContains(t, Verify(&LoadOptions{Pathname: other, PlatformOverlay: true}), "line 1: invalid line format")
+	results, err := ValidateAll(other, &LoadOptions{PlatformOverlay: true})
+	assert.NoError(t, err)
+	assert.Len(t, results[other], 1)
+	assert.ErrorContains(t, results[other][0], "line 1: invalid line format")
+}
+
+func TestLoadExtendsCycle(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.env"), []byte("#extends b.env\n"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.env"), []byte("#extends a.env\n"), 0o600))
+
+	_, err := Load(&LoadOptions{Pathname: filepath.Join(dir, "a.env")})
+	assert.ErrorContains(t, err, "extends cycle")
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "c.env"), []byte("#extends missing.env\n"), 0o600))
+	err = Verify(&LoadOptions{Pathname: filepath.Join(dir, "c.env")})
+	assert.ErrorContains(t, err, "missing.env")
+}
+
+func TestEnvForDir(t *testing.T) {
+	unsetEnv(t, "DIR_ROOT")
+	root := t.TempDir()
+	sub := filepath.Join(root, "apps", "web")
+	assert.NoError(t, os.MkdirAll(sub, 0o755))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(root, ".env"), []byte("DIR_ROOT=root\nDIR_NAME=root\nDIR_DROP=x\n"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(sub, ".env"), []byte("DIR_NAME=web\nunset DIR_DROP\n"), 0o600))
+
+	env, err := EnvForDir(sub)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"DIR_ROOT": "root", "DIR_NAME": "web"}, env)
+
+	_, ok := os.LookupEnv("DIR_ROOT")
+	assert.False(t, ok, "EnvForDir must not set variables")
+
+	env, err = EnvForDir(sub, &LoadOptions{MaxLevels: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"DIR_NAME": "web"}, env)
+}
+
+func TestEnvForDirOptions(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "web")
+	assert.NoError(t, os.MkdirAll(sub, 0o755))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(root, ".env"), []byte("DIR_SOCKET=/var/run/app.sock\nDIR_URL=http://${DIR_HOST}\n"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, ".env."+runtime.GOOS), []byte("DIR_SOCKET=platform.sock\n"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(sub, ".env"), []byte("DIR_NAME=web\n"), 0o600))
+
+	env, err := EnvForDir(sub, &LoadOptions{
+		MaxLevels:       1,
+		PlatformOverlay: true,
+		Extra:           map[string]string{"DIR_HOST": "localhost", "DIR_NAME": "extra"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"DIR_SOCKET": "platform.sock",
+		"DIR_URL":    "http://localhost",
+		"DIR_HOST":   "localhost",
+		"DIR_NAME":   "web",
+	}, env)
+
+	// Invalid lines in any file of the chain are reported, as in Parse
+	assert.NoError(t, os.WriteFile(filepath.Join(root, ".env"), []byte("BAD LINE\n"), 0o600))
+	env, err = EnvForDir(sub, &LoadOptions{MaxLevels: 1})
+	assert.Nil(t, env)
+	var parseErr *ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 1, parseErr.Line)
+}
+
+func TestLoadCSV(t *testing.T) {
+	unsetEnv(t, "CSV_HOST", "CSV_TOKEN", "TSV_PORT")
+	dir := t.TempDir()
+
+	csvPath := filepath.Join(dir, "secrets.csv")
+	content := "name,value,owner\nCSV_HOST,db.internal,ops\nbad-key,x,ops\nCSV_TOKEN,\"a,b\",dev\nCSV_SHORT\n"
+	assert.NoError(t, os.WriteFile(csvPath, []byte(content), 0o600))
+
+	report, err := LoadCSV(csvPath, "name", "value")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Loaded)
+	assert.Len(t, report.Rejected, 2)
+	assert.EqualError(t, report.Rejected[0], "row 3: invalid key format: bad-key")
+	assert.EqualError(t, report.Rejected[1], "row 5: missing columns")
+	assert.Equal(t, "db.internal", os.Getenv("CSV_HOST"))
+	assert.Equal(t, "a,b", os.Getenv("CSV_TOKEN"))
+
+	tsvPath := filepath.Join(dir, "vars.tsv")
+	assert.NoError(t, os.WriteFile(tsvPath, []byte("key\tval\nTSV_PORT\t5432\n"), 0o600))
+	report, err = LoadCSV(tsvPath, "key", "val")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Loaded)
+	assert.Equal(t, "5432", os.Getenv("TSV_PORT"))
+
+	_, err = LoadCSV(tsvPath, "name", "value")
+	assert.ErrorContains(t, err, "header must contain")
+
+	// Excel's "CSV UTF-8" export starts with a byte order mark
+	unsetEnv(t, "CSV_BOM")
+	bomPath := filepath.Join(dir, "excel.csv")
+	assert.NoError(t, os.WriteFile(bomPath, []byte("\ufeffKEY,VALUE\r\nCSV_BOM,1\r\n"), 0o600))
+	report, err = LoadCSV(bomPath, "KEY", "VALUE")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Loaded)
+	assert.Equal(t, "1", os.Getenv("CSV_BOM"))
+}
+
+func TestLoadStdin(t *testing.T) {
+	unsetEnv(t, "STDIN_KEY")
+	path := filepath.Join(t.TempDir(), "stdin.env")
+	assert.NoError(t, os.WriteFile(path, []byte("STDIN_KEY=piped\n"), 0o600))
+
+	stdin, err := os.Open(path)
+	assert.NoError(t, err)
+	defer stdin.Close()
+
+	orig := os.Stdin
+	os.Stdin = stdin
+	t.Cleanup(func() { os.Stdin = orig })
+
+	count, err := Load(&LoadOptions{Pathname: "-"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, "piped", os.Getenv("STDIN_KEY"))
+}
+
+func TestLoadTrace(t *testing.T) {
+	unsetEnv(t, "TRACE_NEW", "TRACE_EMPTY")
+	t.Setenv("TRACE_OLD", "existing")
+	path := filepath.Join(t.TempDir(), "trace.env")
+	content := "# comment\n\nTRACE_NEW=1\nTRACE_OLD=2\nTRACE_EMPTY=\nset -a\nbad line\nunset TRACE_OLD\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	trace := &Trace{}
+	_, err := Load(&LoadOptions{Pathname: path, SkipEmpty: true, Trace: trace})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []TraceLine{
+		{File: path, Line: 1, Kind: "comment", Action: "ignored"},
+		{File: path, Line: 2, Kind: "blank", Action: "ignored"},
+		{File: path, Line: 3, Kind: "assignment", Key: "TRACE_NEW", Action: "set"},
+		{File: path, Line: 4, Kind: "assignment", Key: "TRACE_OLD", Action: "kept", Reason: "already set in environment"},
+		{File: path, Line: 5, Kind: "assignment", Key: "TRACE_EMPTY", Action: "skipped", Reason: "empty value"},
+		{File: path, Line: 6, Kind: "directive", Action: "ignored", Reason: "set -a"},
+		{File: path, Line: 7, Kind: "invalid", Action: "skipped", Reason: "invalid line format, missing equals sign"},
+		{File: path, Line: 8, Kind: "unset", Key: "TRACE_OLD", Action: "kept", Reason: "set outside this file"},
+	}, trace.Lines)
+
+	data, err := json.Marshal(trace)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `{"file":"`+path+`","line":3,"kind":"assignment","key":"TRACE_NEW","action":"set"}`)
+}
+
+func TestLoadGroup(t *testing.T) {
+	unsetEnv(t, "GRP_SHARED", "GRP_WEB", "GRP_WORKER", "GRP_BOTH")
+	path := filepath.Join(t.TempDir(), "procs.env")
+	content := "GRP_SHARED=1\n# @group web\nGRP_WEB=1\n# @group worker\n# queue settings\nGRP_WORKER=1\n# @group web worker\nGRP_BOTH=1\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	count, err := LoadGroup("worker", &LoadOptions{Pathname: path})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, "1", os.Getenv("GRP_SHARED"))
+	assert.Equal(t, "1", os.Getenv("GRP_WORKER"))
+	assert.Equal(t, "1", os.Getenv("GRP_BOTH"))
+	_, ok := os.LookupEnv("GRP_WEB")
+	assert.False(t, ok)
+}
+
+func TestReadEntriesAnnotationScope(t *testing.T) {
+	skip := func(line string) (string, string, error) {
+		if strings.HasPrefix(line, "SKIP") {
+			return "", "", nil
+		}
+		return parseLine(line)
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		options *LoadOptions
+		want    map[string]string
+	}{
+		{name: "attached", input: "# @group worker\nDB=1\n", want: map[string]string{"group": "worker"}},
+		{name: "plain comment in between", input: "# @group worker\n# queue settings\nDB=1\n", want: map[string]string{"group": "worker"}},
+		{name: "blank line", input: "# @group worker\n\n# shared\nDB=1\n"},
+		{name: "directive", input: "# @group worker\nset -a\nDB=1\n"},
+		{name: "unset", input: "# @group worker\nunset OLD\nDB=1\n"},
+		{name: "invalid line", input: "# @group worker\nBAD LINE\nDB=1\n"},
+		{name: "skipped by LineParser", input: "# @group worker\nSKIP this\nDB=1\n", options: &LoadOptions{LineParser: skip}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := tt.options
+			if options == nil {
+				options = &LoadOptions{}
+			}
+			entries, _, err := readEntries(strings.NewReader(tt.input), "", options)
+			assert.NoError(t, err)
+			db := entries[len(entries)-1]
+			assert.Equal(t, "DB", db.key)
+			assert.Equal(t, tt.want, db.annotations)
+		})
+	}
+}
+
+func TestParseAnnotation(t *testing.T) {
+	tests := []struct {
+		input     string
+		wantName  string
+		wantValue string
+		wantOK    bool
+	}{
+		{input: "# @group worker", wantName: "group", wantValue: "worker", wantOK: true},
+		{input: "#@group  web worker ", wantName: "group", wantValue: "web worker", wantOK: true},
+		{input: "# @required", wantName: "required", wantOK: true},
+		{input: "# plain comment", wantOK: false},
+		{input: "# @", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			name, value, ok := parseAnnotation(tt.input)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantName, name)
+			assert.Equal(t, tt.wantValue, value)
+		})
+	}
+}
+
+func TestValidateAll(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "good.env"), []byte("A=1\n"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "bad.env"), []byte("A=1\nnot valid\n#extends missing.env\n"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("not valid\n"), 0o600))
+
+	results, err := ValidateAll(filepath.Join(dir, "*.env"))
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Empty(t, results[filepath.Join(dir, "good.env")])
+
+	badErrs := results[filepath.Join(dir, "bad.env")]
+	assert.Len(t, badErrs, 2)
+	assert.ErrorContains(t, badErrs[0], "line 2: invalid line format")
+	assert.ErrorContains(t, badErrs[1], "missing.env")
+
+	_, err = ValidateAll("[")
+	assert.Error(t, err)
+}
+
+func TestLoadExtendsRoot(t *testing.T) {
+	unsetEnv(t, "ROOT_SECRET")
+	outside := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(outside, "secret.env"), []byte("ROOT_SECRET=leaked\n"), 0o600))
+
+	dir := t.TempDir()
+	assert.NoError(t, os.Symlink(filepath.Join(outside, "secret.env"), filepath.Join(dir, "link.env")))
+
+	tests := []struct {
+		name    string
+		extends string
+	}{
+		{name: "parent traversal", extends: filepath.Join("..", filepath.Base(outside), "secret.env")},
+		{name: "absolute path", extends: filepath.Join(outside, "secret.env")},
+		{name: "symlink", extends: "link.env"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, ".env")
+			assert.NoError(t, os.WriteFile(path, []byte("#extends "+tt.extends+"\n"), 0o600))
+
+			_, err := Load(&LoadOptions{Pathname: path})
+			assert.ErrorIs(t, err, ErrOutsideRoot)
+			_, ok := os.LookupEnv("ROOT_SECRET")
+			assert.False(t, ok)
+		})
+	}
+
+	// The checked path is returned with symlinks resolved, so it is what gets opened
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "shared.env"), []byte("ROOT_SHARED=1\n"), 0o600))
+	assert.NoError(t, os.Symlink("shared.env", filepath.Join(dir, "inside.env")))
+	realPath, err := checkWithinRoot(filepath.Join(dir, "inside.env"), dir)
+	assert.NoError(t, err)
+	realDir, err := filepath.EvalSymlinks(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(realDir, "shared.env"), realPath)
+}
+
+func TestSandbox(t *testing.T) {
+	unsetEnv(t, "SANDBOX_LOADED", "SANDBOX_MISSING")
+	t.Setenv("SANDBOX_ALLOWED", "yes")
+	t.Setenv("SANDBOX_SECRET", "parent-only")
+	path := filepath.Join(t.TempDir(), "sandbox.env")
+	assert.NoError(t, os.WriteFile(path, []byte("SANDBOX_LOADED=1\n"), 0o600))
+
+	_, err := Load(&LoadOptions{Pathname: path})
+	assert.NoError(t, err)
+
+	env := Sandbox([]string{"SANDBOX_ALLOWED", "SANDBOX_MISSING", "SANDBOX_LOADED"})
+	assert.Equal(t, []string{"SANDBOX_ALLOWED=yes", "SANDBOX_LOADED=1"}, env)
+}
+
+func TestLoadFromReaderLineContinuation(t *testing.T) {
+	unsetEnv(t, "CONT_OPTS", "CONT_NEXT", "CONT_ESCAPED", "CONT_LAST")
+
+	input := strings.Join([]string{
+		`CONT_OPTS=-Xmx1g \`,
+		`    -Xms512m \`,
+		`    -XX:+UseG1GC`,
+		`CONT_ESCAPED="ends with \\"`,
+		`CONT_NEXT=1`,
+		`CONT_LAST=eof \`,
+	}, "\n")
+
+	trace := &Trace{}
+	count, err := loadFromReader(strings.NewReader(input), &LoadOptions{Trace: trace})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+	assert.Equal(t, "-Xmx1g -Xms512m -XX:+UseG1GC", os.Getenv("CONT_OPTS"))
+	assert.Equal(t, `ends with \`, os.Getenv("CONT_ESCAPED"))
+	assert.Equal(t, "1", os.Getenv("CONT_NEXT"))
+	assert.Equal(t, "eof", os.Getenv("CONT_LAST"))
+
+	lines := make([]int, len(trace.Lines))
+	for i, l := range trace.Lines {
+		lines[i] = l.Line
+	}
+	assert.Equal(t, []int{1, 4, 5, 6}, lines)
+}
+
+func TestLoadFromReaderCRLF(t *testing.T) {
+	unsetEnv(t, "CRLF_PLAIN", "CRLF_QUOTED", "CRLF_CONT")
+
+	input := "# edited on Windows\r\nCRLF_PLAIN=value\r\nCRLF_QUOTED=\"quoted value\"\r\n\r\nCRLF_CONT=a \\\r\n  b\r\n"
+	count, err := loadFromReader(strings.NewReader(input), &LoadOptions{Strict: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, "value", os.Getenv("CRLF_PLAIN"))
+	assert.Equal(t, "quoted value", os.Getenv("CRLF_QUOTED"))
+	assert.Equal(t, "a b", os.Getenv("CRLF_CONT"))
+}
+
+func TestLoadFromReaderBOM(t *testing.T) {
+	unsetEnv(t, "BOM_FIRST", "BOM_SECOND")
+
+	count, err := loadFromReader(strings.NewReader("\ufeffBOM_FIRST=1\r\nBOM_SECOND=2\r\n"), &LoadOptions{Strict: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, "1", os.Getenv("BOM_FIRST"))
+	assert.Equal(t, "2", os.Getenv("BOM_SECOND"))
+
+	// Only a leading byte order mark is stripped
+	_, err = loadFromReader(strings.NewReader("BOM_FIRST=1\n\ufeffBOM_SECOND=2\n"), &LoadOptions{Strict: true})
+	assert.ErrorContains(t, err, "line 2: invalid key format")
+}
+
+func TestLoadFromReaderUTF16(t *testing.T) {
+	unsetEnv(t, "UTF16_NAME", "UTF16_CITY")
+
+	// "Ċ" and "ഊ" contain '\n' and '\r' bytes in UTF-16 and must not split lines
+	text := "\ufeffUTF16_NAME=Ċarol\r\nUTF16_CITY=\"ഊ # quoted\"\r\n"
+	for _, order := range []binary.AppendByteOrder{binary.LittleEndian, binary.BigEndian} {
+		var data []byte
+		for _, unit := range utf16.Encode([]rune(text)) {
+			data = order.AppendUint16(data, unit)
+		}
+
+		count, err := loadFromReader(bytes.NewReader(data), &LoadOptions{Strict: true, Overwrite: true})
+		assert.NoError(t, err, order.String())
+		assert.Equal(t, 2, count)
+		assert.Equal(t, "Ċarol", os.Getenv("UTF16_NAME"))
+		assert.Equal(t, "ഊ # quoted", os.Getenv("UTF16_CITY"))
+
+		_, err = loadFromReader(bytes.NewReader(data[:len(data)-1]), DefaultLoadOptions())
+		assert.ErrorContains(t, err, "invalid UTF-16: odd number of bytes")
+	}
+}
+
+func TestLoadFromReaderCommentPrefixes(t *testing.T) {
+	unsetEnv(t, "INI_HOST", "INI_URL")
+
+	input := "; exported from settings.ini\n// generated\nINI_HOST=localhost\nINI_URL=http://localhost//api\n"
+	count, err := loadFromReader(strings.NewReader(input), &LoadOptions{Strict: true, CommentPrefixes: []string{";", "//"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, "http://localhost//api", os.Getenv("INI_URL"))
+
+	// "#" stays a comment prefix
+	count, err = loadFromReader(strings.NewReader("; c\n// d\n# e\nINI_HOST=db\n"), &LoadOptions{Strict: true, Overwrite: true, CommentPrefixes: []string{";", "//"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, "db", os.Getenv("INI_HOST"))
+
+	_, err = loadFromReader(strings.NewReader("; not a comment by default\n"), &LoadOptions{Strict: true})
+	assert.ErrorContains(t, err, "line 1: invalid")
+}
+
+func TestLoadFromReaderRawValues(t *testing.T) {
+	unsetEnv(t, "RAW_QUOTED", "RAW_SPACES", "RAW_COMMENT", "RAW_REF", "RAW_SLASH")
+
+	input := strings.Join([]string{
+		`RAW_QUOTED="keep the quotes"`,
+		"  export RAW_SPACES =  padded  ",
+		"RAW_COMMENT=a # not a comment",
+		"RAW_REF=${HOME}\\n",
+		"RAW_SLASH=ends with \\",
+	}, "\r\n")
+	count, err := loadFromReader(strings.NewReader(input), &LoadOptions{RawValues: true, Strict: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, count)
+	assert.Equal(t, `"keep the quotes"`, os.Getenv("RAW_QUOTED"))
+	assert.Equal(t, "  padded  ", os.Getenv("RAW_SPACES"))
+	assert.Equal(t, "a # not a comment", os.Getenv("RAW_COMMENT"))
+	assert.Equal(t, `${HOME}\n`, os.Getenv("RAW_REF"))
+	assert.Equal(t, `ends with \`, os.Getenv("RAW_SLASH"))
+
+	_, err = loadFromReader(strings.NewReader("not-a-key=1\n"), &LoadOptions{RawValues: true, Strict: true})
+	assert.ErrorContains(t, err, "line 1: invalid key format: not-a-key")
+
+	// Only "export" followed by whitespace is the keyword
+	vars, err := Parse(strings.NewReader("exporter=1\n"), &LoadOptions{RawValues: true})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"exporter": "1"}, vars)
+}
+
+func TestLoadFromReaderRawBlocks(t *testing.T) {
+	unsetEnv(t, "BLOCK_TEMPLATE", "BLOCK_REGEX", "BLOCK_AFTER")
+
+	input := strings.Join([]string{
+		`BLOCK_TEMPLATE="""`,
+		`Hello ${NAME},`,
+		`  "quoted" \n # kept \`,
+		`"""  # trailing comment`,
+		`export BLOCK_REGEX = """^\d+\.\d+$"""`,
+		`BLOCK_AFTER=${BLOCK_REGEX}`,
+	}, "\r\n")
+	trace := &Trace{}
+	count, err := loadFromReader(strings.NewReader(input), &LoadOptions{Strict: true, Trace: trace})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, "Hello ${NAME},\n  \"quoted\" \\n # kept \\\n", os.Getenv("BLOCK_TEMPLATE"))
+	assert.Equal(t, `^\d+\.\d+$`, os.Getenv("BLOCK_REGEX"))
+	assert.Equal(t, `^\d+\.\d+$`, os.Getenv("BLOCK_AFTER"))
+	assert.Equal(t, 5, trace.Lines[1].Line)
+
+	_, err = loadFromReader(strings.NewReader("BLOCK_OPEN=\"\"\"never closed\nA=1\n"), &LoadOptions{Strict: true})
+	assert.ErrorContains(t, err, `line 1: unterminated """ block`)
+
+	_, err = loadFromReader(strings.NewReader(`BLOCK_JUNK="""a""" b`+"\n"), &LoadOptions{Strict: true})
+	assert.ErrorContains(t, err, `line 1: unexpected text after closing """: b`)
+}
+
+func TestParseAssignmentDialect(t *testing.T) {
+	dialect := Dialect{Assign: ":=", Quotes: "\"'`"}
+
+	tests := []struct {
+		input     string
+		wantKey   string
+		wantValue string
+		wantQuote byte
+	}{
+		{input: "HOST: db.local", wantKey: "HOST", wantValue: "db.local"},
+		{input: "HOST=db.local", wantKey: "HOST", wantValue: "db.local"},
+		{input: "URL: http://x:8080", wantKey: "URL", wantValue: "http://x:8080"},
+		{input: "CMD: `echo a:b` # comment", wantKey: "CMD", wantValue: "echo a:b", wantQuote: '`'},
+		{input: `MSG: "a\tb"`, wantKey: "MSG", wantValue: "a\tb", wantQuote: '"'},
+	}
+
+	for _, tt := range tests {
+		key, value, quote, err := parseAssignment(tt.input, dialect, keyMapping{})
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, tt.wantKey, key, tt.input)
+		assert.Equal(t, tt.wantValue, value, tt.input)
+		assert.Equal(t, tt.wantQuote, quote, tt.input)
+	}
+
+	unsetEnv(t, "DIALECT_A", "DIALECT_B")
+	_, err := loadFromReader(strings.NewReader("DIALECT_A: `one`\nDIALECT_B: ${DIALECT_A}\n"), &LoadOptions{Dialect: dialect, Strict: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "one", os.Getenv("DIALECT_A"))
+	assert.Equal(t, "one", os.Getenv("DIALECT_B"))
+
+	_, err = loadFromReader(strings.NewReader("DIALECT_A: one\n"), &LoadOptions{Strict: true})
+	assert.ErrorContains(t, err, "missing equals sign", "the default dialect only accepts '='")
+}
+
+func TestParseAssignmentLiteralQuotes(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		literal string
+	}{
+		{input: `FLAG='--name="x y"'`, want: `--name="x y"`, literal: `--name="x y"`},
+		{input: `FLAG="--name='x y'" # comment`, want: `--name='x y'`, literal: `--name='x y'`},
+		{input: `FLAG="--name=\"x y\""`, want: `--name="x y"`, literal: `--name=\"x y\"`},
+		{input: `FLAG="a\tb"`, want: "a\tb", literal: `a\tb`},
+		{input: `FLAG="--a='1'" "--b='2'" # comment`, want: `"--a='1'" "--b='2'" # comment`, literal: `--a='1'" "--b='2'`},
+		{input: `FLAG=--name="x # y" # comment`, want: `--name="x # y"`, literal: `--name="x # y"`},
+		{input: `FLAG=it's # comment`, want: "it's", literal: "it's"},
+		{input: `FLAG='mismatched"`, want: `'mismatched"`, literal: `'mismatched"`},
+	}
+
+	for _, tt := range tests {
+		_, value, _, err := parseAssignment(tt.input, Dialect{}, keyMapping{})
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, tt.want, value, tt.input)
+
+		_, value, _, err = parseAssignment(tt.input, Dialect{LiteralQuotes: true}, keyMapping{})
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, tt.literal, value, tt.input)
+	}
+}
+
+func TestLoadFromReaderDialectPresets(t *testing.T) {
+	input := strings.Join([]string{
+		"COMPAT_NAME=app",
+		`COMPAT_ESCAPES="a\nb\tc\"d"`,
+		"COMPAT_REF=${COMPAT_NAME}-1",
+		"COMPAT_SINGLE='${COMPAT_NAME}'",
+		"COMPAT_BACKTICK=`x`",
+	}, "\n")
+
+	tests := []struct {
+		name     string
+		dialect  Dialect
+		escapes  string
+		ref      string
+		backtick string
+	}{
+		{name: "compose", dialect: DialectCompose, escapes: "a\nb\tc\"d", ref: "app-1", backtick: "`x`"},
+		{name: "bash", dialect: DialectBash, escapes: `a\nb\tc"d`, ref: "app-1", backtick: "`x`"},
+		{name: "node", dialect: DialectNode, escapes: "a\nb\\tc\\\"d", ref: "${COMPAT_NAME}-1", backtick: "x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "COMPAT_NAME", "COMPAT_ESCAPES", "COMPAT_REF", "COMPAT_SINGLE", "COMPAT_BACKTICK")
+			_, err := loadFromReader(strings.NewReader(input), &LoadOptions{Dialect: tt.dialect, Strict: true})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.escapes, os.Getenv("COMPAT_ESCAPES"))
+			assert.Equal(t, tt.ref, os.Getenv("COMPAT_REF"))
+			assert.Equal(t, "${COMPAT_NAME}", os.Getenv("COMPAT_SINGLE"))
+			assert.Equal(t, tt.backtick, os.Getenv("COMPAT_BACKTICK"))
+		})
+	}
+}
+
+func TestLoadFromReaderNormalizeKeys(t *testing.T) {
+	unsetEnv(t, "DB_PORT", "APP_SERVER_NAME", "LOWER")
+
+	input := "db-port=5432\napp.server.name=web\nlower=1\nunset lower\n"
+	count, err := loadFromReader(strings.NewReader(input), &LoadOptions{NormalizeKeys: true, Strict: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, "5432", os.Getenv("DB_PORT"))
+	assert.Equal(t, "web", os.Getenv("APP_SERVER_NAME"))
+	_, ok := os.LookupEnv("LOWER")
+	assert.False(t, ok)
+
+	_, err = loadFromReader(strings.NewReader("db port=1\n"), &LoadOptions{NormalizeKeys: true, Strict: true})
+	assert.ErrorContains(t, err, "line 1: invalid key format: DB PORT")
+
+	_, err = loadFromReader(strings.NewReader("db-port=1\n"), &LoadOptions{Strict: true})
+	assert.ErrorContains(t, err, "line 1: invalid key format: db-port", "keys are only normalized on request")
+}
+
+func TestLoadFromReaderKeyDelimiter(t *testing.T) {
+	unsetEnv(t, "APP_SERVER_PORT", "APP_SERVER_URL", "DEBUG", "APP_OLD")
+
+	input := "app.server.port=8080\napp.server.url=http://localhost:${APP_SERVER_PORT}\ndebug=1\napp.old=1\nunset app.old\n"
+	count, err := loadFromReader(strings.NewReader(input), &LoadOptions{KeyDelimiter: ".", Strict: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+	assert.Equal(t, "8080", os.Getenv("APP_SERVER_PORT"))
+	assert.Equal(t, "http://localhost:8080", os.Getenv("APP_SERVER_URL"))
+	assert.Equal(t, "1", os.Getenv("DEBUG"))
+	_, ok := os.LookupEnv("APP_OLD")
+	assert.False(t, ok)
+
+	os.Unsetenv("APP_SERVER_PORT")
+	_, err = loadFromReader(strings.NewReader("app::server::port=9090\n"), &LoadOptions{KeyDelimiter: "::", Strict: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "9090", os.Getenv("APP_SERVER_PORT"))
+
+	_, err = loadFromReader(strings.NewReader("app-server.port=1\n"), &LoadOptions{KeyDelimiter: ".", Strict: true})
+	assert.ErrorContains(t, err, "line 1: invalid key format: APP-SERVER_PORT")
+}
+
+func TestLoadFromReaderDecodeBase64(t *testing.T) {
+	unsetEnv(t, "SA_JSON", "SA_UNPADDED", "APP_KEY")
+
+	input := strings.Join([]string{
+		"# @decode base64",
+		"SA_JSON=eyJ0eXBlIjogInNlcnZpY2VfYWNjb3VudCIsICJrZXkiOiAiJHtOT1RfQV9SRUZ9In0=",
+		"# @decode base64",
+		"SA_UNPADDED=aGk",
+		"APP_KEY=base64:c2VjcmV0",
+	}, "\n")
+	count, err := loadFromReader(strings.NewReader(input), &LoadOptions{Strict: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, `{"type": "service_account", "key": "${NOT_A_REF}"}`, os.Getenv("SA_JSON"))
+	assert.Equal(t, "hi", os.Getenv("SA_UNPADDED"))
+	assert.Equal(t, "base64:c2VjcmV0", os.Getenv("APP_KEY"), "values are only decoded when annotated")
+
+	_, err = loadFromReader(strings.NewReader("# @decode base64\nSA_JSON=not base64!\n"), &LoadOptions{Strict: true})
+	assert.ErrorContains(t, err, "line 2: invalid base64 value")
+
+	_, err = loadFromReader(strings.NewReader("# @decode rot13\nSA_JSON=x\n"), &LoadOptions{Strict: true})
+	assert.ErrorContains(t, err, "line 2: unknown @decode encoding: rot13")
+
+	// The annotation belongs to the invalid line and must not decode the next key
+	unsetEnv(t, "PLAIN")
+	count, err = loadFromReader(strings.NewReader("# @decode base64\nBAD LINE\nPLAIN=hello\n"), DefaultLoadOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, "hello", os.Getenv("PLAIN"))
+}
+
+func TestLoadFromReaderLineParser(t *testing.T) {
+	unsetEnv(t, "CUSTOM_HOST", "CUSTOM_URL")
+
+	// "set KEY to VALUE" lines; "enc:" lines are handled elsewhere
+	parser := func(line string) (string, string, error) {
+		if strings.HasPrefix(line, "enc:") {
+			return "", "", nil
+		}
+		rest, ok := strings.CutPrefix(line, "set ")
+		key, value, found := strings.Cut(rest, " to ")
+		if !ok || !found {
+			return "", "", errors.New("expected: set KEY to VALUE")
+		}
+		return key, value, nil
+	}
+
+	input := "# comment\nset CUSTOM_HOST to db.local\nset CUSTOM_URL to pg://${CUSTOM_HOST}\nenc:AAAA\nCUSTOM_HOST=ignored\nset bad-key to x\n"
+	trace := &Trace{}
+	count, err := loadFromReader(strings.NewReader(input), &LoadOptions{LineParser: parser, Trace: trace})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, "db.local", os.Getenv("CUSTOM_HOST"))
+	assert.Equal(t, "pg://db.local", os.Getenv("CUSTOM_URL"))
+
+	reasons := make([]string, 0, len(trace.Lines))
+	for _, line := range trace.Lines {
+		reasons = append(reasons, line.Action+" "+line.Reason)
+	}
+	assert.Equal(t, []string{
+		"ignored ",
+		"set ",
+		"set ",
+		"ignored skipped by LineParser",
+		"skipped expected: set KEY to VALUE",
+		"skipped invalid key format: bad-key",
+	}, reasons)
+}
+
+func TestFreeze(t *testing.T) {
+	unsetEnv(t, "FROZEN_KEY")
+	t.Cleanup(func() { frozen.Store(false) })
+
+	Freeze()
+	assert.True(t, Frozen())
+
+	_, err := loadFromReader(strings.NewReader("FROZEN_KEY=1\n"), DefaultLoadOptions())
+	assert.ErrorIs(t, err, ErrFrozen)
+
+	path := filepath.Join(t.TempDir(), "frozen.env")
+	assert.NoError(t, os.WriteFile(path, []byte("FROZEN_KEY=1\n"), 0o600))
+	_, err = Load(&LoadOptions{Pathname: path})
+	assert.ErrorIs(t, err, ErrFrozen)
+
+	_, ok := os.LookupEnv("FROZEN_KEY")
+	assert.False(t, ok)
+	assert.NoError(t, Verify(&LoadOptions{Pathname: path}), "read-only checks still work")
+}
+
+func TestLoadStrict(t *testing.T) {
+	unsetEnv(t, "STRICT_OK")
+	path := filepath.Join(t.TempDir(), "strict.env")
+	assert.NoError(t, os.WriteFile(path, []byte("STRICT_OK=1\nnot valid\nmy-key=2\n"), 0o600))
+
+	_, err := Load(&LoadOptions{Pathname: path, Strict: true})
+	assert.ErrorContains(t, err, "line 2: invalid line format")
+	assert.ErrorContains(t, err, "line 3: invalid key format: my-key")
+	_, ok := os.LookupEnv("STRICT_OK")
+	assert.False(t, ok, "strict mode must not load a partial environment")
+
+	var parseErrs []*ParseError
+	for _, e := range errors.Unwrap(err).(interface{ Unwrap() []error }).Unwrap() {
+		var parseErr *ParseError
+		if assert.ErrorAs(t, e, &parseErr) {
+			parseErrs = append(parseErrs, parseErr)
+		}
+	}
+	assert.Equal(t, []*ParseError{
+		{File: path, Line: 2, Text: "not valid", Reason: "invalid line format, missing equals sign"},
+		{File: path, Line: 3, Text: "my-key=2", Reason: "invalid key format: my-key"},
+	}, parseErrs)
+
+	count, err := Load(&LoadOptions{Pathname: path})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestLoadDuplicatePolicy(t *testing.T) {
+	content := "DUP_KEY=first\nDUP_KEY=second\nunset DUP_KEY\nDUP_KEY=third\nDUP_KEY=fourth\n"
+
+	tests := []struct {
+		policy    DuplicatePolicy
+		overwrite bool
+		want      string
+	}{
+		{policy: DuplicateAllow, want: "third"},
+		{policy: DuplicateAllow, overwrite: true, want: "fourth"},
+		{policy: FirstWins, overwrite: true, want: "third"},
+		{policy: LastWins, want: "fourth"},
+	}
+
+	for _, tt := range tests {
+		unsetEnv(t, "DUP_KEY")
+		path := filepath.Join(t.TempDir(), "dup.env")
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+		_, err := Load(&LoadOptions{Pathname: path, DuplicatePolicy: tt.policy, Overwrite: tt.overwrite})
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, os.Getenv("DUP_KEY"), "policy %d, overwrite %v", tt.policy, tt.overwrite)
+	}
+
+	unsetEnv(t, "DUP_KEY", "DUP_OTHER")
+	path := filepath.Join(t.TempDir(), "dup.env")
+	assert.NoError(t, os.WriteFile(path, []byte("DUP_OTHER=1\nDUP_KEY=a\nDUP_KEY=b\n"), 0o600))
+
+	_, err := Load(&LoadOptions{Pathname: path, DuplicatePolicy: DuplicateError})
+	var parseErr *ParseError
+	if assert.ErrorAs(t, err, &parseErr) {
+		assert.Equal(t, &ParseError{File: path, Line: 3, Text: "DUP_KEY=b", Reason: "duplicate key DUP_KEY, first assigned on line 2"}, parseErr)
+	}
+	assert.Equal(t, 1, strings.Count(err.Error(), path), "path is reported once")
+	_, ok := os.LookupEnv("DUP_OTHER")
+	assert.False(t, ok)
+
+	trace := &Trace{}
+	_, err = Load(&LoadOptions{Pathname: path, DuplicatePolicy: LastWins, Trace: trace})
+	assert.NoError(t, err)
+	assert.Equal(t, TraceLine{File: path, Line: 2, Kind: "duplicate", Key: "DUP_KEY", Action: "ignored", Reason: "overridden on line 3"}, trace.Lines[1])
+	assert.Equal(t, "b", os.Getenv("DUP_KEY"))
+
+	// Assignments for different groups are not duplicates
+	assert.NoError(t, os.WriteFile(path, []byte("# @group web\nDUP_KEY=web\n# @group worker\nDUP_KEY=worker\n"), 0o600))
+	assert.NoError(t, Verify(&LoadOptions{Pathname: path, DuplicatePolicy: DuplicateError}))
+}
+
+func TestExecErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exec.env")
+	assert.NoError(t, os.WriteFile(path, []byte("EXEC_KEY=1\n"), 0o600))
+	unsetEnv(t, "EXEC_KEY")
+
+	err := Exec(context.Background(), &LoadOptions{Pathname: path}, nil)
+	assert.ErrorContains(t, err, "no command given")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = Exec(ctx, &LoadOptions{Pathname: path}, []string{"true"})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	err = Exec(context.Background(), &LoadOptions{Pathname: path}, []string{"quickenv-no-such-command"})
+	assert.ErrorIs(t, err, exec.ErrNotFound)
+}
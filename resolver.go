@@ -0,0 +1,67 @@
+package quickenv
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	resolverMu sync.RWMutex
+	resolvers  map[string]func(ref string) (string, error)
+)
+
+// RegisterResolver makes "${scheme:ref}" references in values resolve at load time by
+// calling resolve with ref, so a single env file can mix static values and secret references:
+//
+//	quickenv.RegisterResolver("vault", func(ref string) (string, error) {
+//		return readVaultSecret(ref) // e.g. "secret/data/app#password"
+//	})
+//
+//	DB_PASSWORD=${vault:secret/data/app#password}
+//
+// The reference is expanded itself before it is resolved, so it can depend on other
+// variables, e.g. "${vault:secret/${APP_ENV}/db}". An error from resolve fails the load.
+// References to schemes without a resolver are kept literally, and single-quoted values
+// are never resolved. Passing a nil resolve removes the scheme.
+//
+// Register resolvers before loading; resolve may be called from several goroutines.
+func RegisterResolver(scheme string, resolve func(ref string) (string, error)) {
+	resolverMu.Lock()
+	defer resolverMu.Unlock()
+	if resolve == nil {
+		delete(resolvers, scheme)
+		return
+	}
+	if resolvers == nil {
+		resolvers = make(map[string]func(string) (string, error))
+	}
+	resolvers[scheme] = resolve
+}
+
+// resolveRef resolves the inside of a "${scheme:ref}" reference with the resolver
+// registered for scheme. ok is false if expr is not such a reference, e.g. "NAME:-word".
+// The reference is expanded with expand first.
+func resolveRef(expr string, expand func(string) (string, error)) (value string, ok bool, err error) {
+	scheme, ref, found := strings.Cut(expr, ":")
+	if !found || ref == "" || strings.ContainsRune("-?+", rune(ref[0])) {
+		return "", false, nil
+	}
+
+	resolverMu.RLock()
+	resolve := resolvers[scheme]
+	resolverMu.RUnlock()
+	if resolve == nil {
+		return "", false, nil
+	}
+
+	ref, err = expand(ref)
+	if err != nil {
+		return "", true, err
+	}
+	value, err = resolve(ref)
+	if err != nil {
+		return "", true, fmt.Errorf("%s resolver: %w", scheme, err)
+	}
+	return value, true, nil
+}
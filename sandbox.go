@@ -0,0 +1,33 @@
+package quickenv
+
+import (
+	"os"
+	"slices"
+)
+
+// Sandbox returns a minimal environment in os/exec "KEY=VALUE" form for launching
+// plugins or subprocesses without leaking the parent's full environment.
+// It contains the allowed variables (e.g. "PATH", "HOME") plus the keys assigned by
+// the most recent Load, with their current values. Unset variables are left out.
+//
+//	cmd := exec.Command("plugin")
+//	cmd.Env = quickenv.Sandbox([]string{"PATH", "HOME"})
+func Sandbox(allowed []string) []string {
+	lastLoad.Lock()
+	keys := slices.Concat(allowed, lastLoad.keys)
+	lastLoad.Unlock()
+
+	env := make([]string, 0, len(keys))
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
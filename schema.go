@@ -0,0 +1,108 @@
+package quickenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// jsonSchema is the JSON Schema written by WriteJSONSchema.
+type jsonSchema struct {
+	Schema     string                    `json:"$schema"`
+	Type       string                    `json:"type"`
+	Properties map[string]schemaProperty `json:"properties"`
+	Required   []string                  `json:"required,omitempty"`
+}
+
+// schemaProperty describes one key of a jsonSchema.
+type schemaProperty struct {
+	Type    string   `json:"type"`
+	Format  string   `json:"format,omitempty"`
+	Pattern string   `json:"pattern,omitempty"`
+	Enum    []string `json:"enum,omitempty"`
+}
+
+// durationPattern matches the non-negative durations accepted by time.ParseDuration.
+const durationPattern = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+// schemaTypes maps "# @type" annotations to JSON Schema properties.
+var schemaTypes = map[string]schemaProperty{
+	"string":   {Type: "string"},
+	"int":      {Type: "integer"},
+	"integer":  {Type: "integer"},
+	"float":    {Type: "number"},
+	"number":   {Type: "number"},
+	"bool":     {Type: "boolean"},
+	"boolean":  {Type: "boolean"},
+	"duration": {Type: "string", Pattern: durationPattern},
+	"url":      {Type: "string", Format: "uri"},
+	"email":    {Type: "string", Format: "email"},
+}
+
+// WriteJSONSchema writes a JSON Schema describing the keys of the env file, typically
+// an annotated .env.example, so external validators, UIs and documentation portals
+// can consume the configuration contract. Nothing is set in the environment and values
+// are not expanded.
+//
+// Every assigned key becomes a property. The annotations above a key describe it:
+//   - "# @type T" sets the type; T is one of string (the default), int, float, bool,
+//     duration, url or email
+//   - "# @enum a b c" lists the allowed values
+//   - "# @required" adds the key to the required keys
+//
+// The annotations of a key follow Result.Annotations.
+func WriteJSONSchema(w io.Writer, opts ...*LoadOptions) error {
+	options, err := resolveOptions(opts...)
+	if err != nil {
+		return err
+	}
+	options.DisableExpansion = true // placeholders like ${DB_PASSWORD:?} must not fail
+
+	parsed, err := readEnvFile(options)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]bool)
+	for _, e := range parsed.entries {
+		switch {
+		case e.key == "" || e.ignored != "" || e.file == extraFile:
+		case e.unset:
+			delete(keys, e.key)
+		default:
+			keys[e.key] = true
+		}
+	}
+
+	schema := jsonSchema{
+		Schema:     "https://json-schema.org/draft/2020-12/schema",
+		Type:       "object",
+		Properties: make(map[string]schemaProperty, len(keys)),
+	}
+	annotated := annotations(parsed.entries)
+	for _, key := range slices.Sorted(maps.Keys(keys)) {
+		typ := annotated[key]["type"]
+		if typ == "" {
+			typ = "string"
+		}
+		property, ok := schemaTypes[typ]
+		if !ok {
+			return fmt.Errorf("quickenv: %s: unknown @type: %s", key, typ)
+		}
+		if enum, ok := annotated[key]["enum"]; ok {
+			property.Enum = strings.Fields(enum)
+		}
+		schema.Properties[key] = property
+
+		if _, ok := annotated[key]["required"]; ok {
+			schema.Required = append(schema.Required, key)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(schema)
+}
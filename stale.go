@@ -0,0 +1,81 @@
+package quickenv
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// lastLoad remembers which file the most recent successful Load read, the
+// modification times of it and the files it pulled in, and the keys it assigned.
+var lastLoad struct {
+	sync.Mutex
+	path  string
+	files map[string]time.Time // absolute path -> modification time when loaded
+	keys  []string
+}
+
+// recordLoad stores the path, modification time and assigned keys of a successfully
+// loaded file, plus the modification times of the "#extends" files and platform
+// overlay that contributed entries to it. Paths are made absolute, so a later
+// os.Chdir doesn't make StaleSince check another file.
+func recordLoad(path string, modTime time.Time, entries []entry) {
+	keys := assignedKeys(entries)
+
+	files := make(map[string]time.Time)
+	if abs, err := filepath.Abs(path); err == nil {
+		files[abs] = modTime
+	}
+	for _, e := range entries {
+		if e.file == "" || e.file == path || e.file == extraFile {
+			continue
+		}
+		abs, err := filepath.Abs(e.file)
+		if err != nil {
+			continue
+		}
+		if _, ok := files[abs]; ok {
+			continue
+		}
+		if info, err := os.Stat(abs); err == nil {
+			files[abs] = info.ModTime()
+		}
+	}
+
+	lastLoad.Lock()
+	defer lastLoad.Unlock()
+	lastLoad.path = path
+	lastLoad.files = files
+	lastLoad.keys = keys
+}
+
+// StaleSince reports whether the env file read by the most recent Load, or a file
+// it pulled in with "#extends" or PlatformOverlay, has been modified since, i.e. it
+// was edited but never reloaded. Files that only held comments, and overlays
+// created after the load, are not tracked.
+// If so, it returns how long ago the most recent change was made and true.
+// It returns 0 and false if nothing was loaded yet, the files are unchanged,
+// or they can no longer be inspected.
+func StaleSince() (time.Duration, bool) {
+	lastLoad.Lock()
+	files := maps.Clone(lastLoad.files)
+	lastLoad.Unlock()
+
+	var changed time.Time
+	for path, loadedModTime := range files {
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().Equal(loadedModTime) {
+			continue
+		}
+		if info.ModTime().After(changed) {
+			changed = info.ModTime()
+		}
+	}
+
+	if changed.IsZero() {
+		return 0, false
+	}
+	return time.Since(changed), true
+}
@@ -0,0 +1,64 @@
+package quickenv
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// secretKeyParts are the key name fragments that make Summary redact a value.
+var secretKeyParts = []string{"PASSWORD", "PASSWD", "SECRET", "TOKEN", "KEY", "CREDENTIAL", "PRIVATE", "AUTH"}
+
+// Summary writes an aligned "effective config" table of the keys in r: the current
+// value of each key and where it came from, see Result.Sources. It is meant to be
+// printed once at startup, typically from the PostLoad hook.
+//
+// With redact, values of keys that look like secrets (names containing PASSWORD,
+// SECRET, TOKEN, KEY and the like) are replaced with "***".
+func (r Result) Summary(w io.Writer, redact bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+	for _, key := range r.Keys {
+		value := os.Getenv(key)
+		if redact && value != "" && looksSecret(key) {
+			value = "***"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", key, strings.ReplaceAll(value, "\n", `\n`), r.Sources[key])
+	}
+	return tw.Flush()
+}
+
+// looksSecret reports whether the key name suggests a secret value, see Summary.
+func looksSecret(key string) bool {
+	key = strings.ToUpper(key)
+	for _, part := range secretKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// traceSources derives Result.Sources from the trace of a load.
+func traceSources(trace *Trace) map[string]string {
+	if trace == nil {
+		return nil
+	}
+
+	sources := make(map[string]string)
+	for _, line := range trace.Lines {
+		switch {
+		case line.Kind != "assignment":
+			continue
+		case line.Action == "set":
+			sources[line.Key] = entryPos(entry{file: line.File, line: line.Line})
+		case line.Action == "kept":
+			sources[line.Key] = "environment"
+		case line.Action == "skipped" && sources[line.Key] == "":
+			sources[line.Key] = "skipped: " + line.Reason
+		}
+	}
+	return sources
+}
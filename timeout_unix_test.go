@@ -0,0 +1,31 @@
+//go:build unix
+
+package quickenv
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTimeout(t *testing.T) {
+	// Opening a FIFO for reading blocks until a writer shows up,
+	// which stands in for a hung network file system.
+	path := filepath.Join(t.TempDir(), "hung.env")
+	assert.NoError(t, syscall.Mkfifo(path, 0o600))
+	t.Cleanup(func() {
+		// Unblock the reader left behind by the timed out load
+		if w, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+			w.Close()
+		}
+	})
+
+	start := time.Now()
+	_, err := Load(&LoadOptions{Pathname: path, Timeout: 50 * time.Millisecond})
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
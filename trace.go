@@ -0,0 +1,45 @@
+package quickenv
+
+// Trace is a machine-readable record of the decisions made by Load: every line of
+// the env file, how it was classified and what was done with it. It can be encoded
+// with encoding/json and attached to a support ticket instead of debug output.
+// Values are never recorded.
+type Trace struct {
+	Lines []TraceLine `json:"lines"`
+}
+
+// TraceLine describes how one line was classified and handled.
+type TraceLine struct {
+	// File is the source of the line: a path, "-" for stdin, or empty for in-memory readers
+	File string `json:"file,omitempty"`
+
+	// Line is the 1-based line number in File
+	Line int `json:"line"`
+
+	// Kind is one of "assignment", "unset", "extends", "blank", "comment", "directive",
+	// "invalid", "duplicate" (see DuplicatePolicy) or "overridden" (see Collision)
+	Kind string `json:"kind"`
+
+	// Key is the variable the line refers to, if any
+	Key string `json:"key,omitempty"`
+
+	// Action is one of "set", "kept", "unset", "skipped", "ignored" or "resolved"
+	Action string `json:"action"`
+
+	// Reason explains the action, e.g. "already set in environment"
+	Reason string `json:"reason,omitempty"`
+}
+
+// add appends a line to the trace. It is a no-op on a nil trace.
+func (t *Trace) add(line TraceLine) {
+	if t != nil {
+		t.Lines = append(t.Lines, line)
+	}
+}
+
+// reset clears the trace before a new load. It is a no-op on a nil trace.
+func (t *Trace) reset() {
+	if t != nil {
+		t.Lines = nil
+	}
+}
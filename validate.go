@@ -0,0 +1,61 @@
+package quickenv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ValidateAll checks every env file matching the glob pattern (e.g. "deploy/*.env")
+// concurrently, without setting any environment variables, so a single CI job can
+// check .env.example, .env.test and all deployment files at once.
+//
+// The result maps each matched file to its problems: invalid lines, unreadable files,
+// broken "#extends" directives or, with CollisionError, key collisions.
+// Valid files map to a nil slice.
+// The error is only set if the pattern is malformed.
+func ValidateAll(pattern string, opts ...*LoadOptions) (map[string][]error, error) {
+	options := parseOptions(opts...)
+
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("quickenv: %w", err)
+	}
+
+	results := make(map[string][]error, len(paths))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, path := range paths {
+		wg.Go(func() {
+			errs := validateFile(path, options)
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[path] = errs
+		})
+	}
+
+	wg.Wait()
+	return results, nil
+}
+
+// validateFile reads the file at path the way Load does, with its "#extends"
+// directives and platform overlay, and expands its values, returning every problem found.
+func validateFile(path string, options *LoadOptions) []error {
+	file, err := os.Open(path)
+	if err != nil {
+		return []error{err}
+	}
+	defer file.Close()
+
+	entries, lineErrs, err := loadEntries(file, path, options)
+	if err != nil {
+		return append(lineErrs, err)
+	}
+	if err := reportCollisions(collisions(entries), options); err != nil {
+		lineErrs = append(lineErrs, err)
+	}
+	return lineErrs
+}